@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// page is a single page of a listing together with the information to fetch the other pages
+type page struct {
+	Items  interface{} `json:"items"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// clubsHandler lists the clubs registered in the year given by ?year=, defaulting to the season year
+// of config, paginated with ?limit= and ?offset=
+func clubsHandler(store form.Store, config form.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+
+		year, ok := queryInt(w, r, "year", seasonYear(config, time.Now()))
+		if !ok {
+			return
+		}
+
+		limit, offset, ok := pagination(w, r)
+		if !ok {
+			return
+		}
+
+		clubs, total, err := store.Clubs(r.Context(), year, limit, offset)
+		if err != nil {
+			log.WithField("error", err).Error("Failed to list clubs")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		respond(w, r, http.StatusOK, page{clubs, total, limit, offset})
+	}
+}
+
+// seasonYear is the year registrations submitted at now are stored under: SeasonYear when it is
+// configured, otherwise the year in the location of the handler
+func seasonYear(config form.Config, now time.Time) int {
+	if config.SeasonYear != 0 {
+		return config.SeasonYear
+	}
+	if config.Location != nil {
+		now = now.In(config.Location)
+	}
+
+	return now.Year()
+}
+
+// auditHandler lists the ?limit= most recently handled submissions, the newest first, of the default
+// handler or of the tenant named by ?tenant=
+func auditHandler(formHandler form.Handler, tenants []*tenant) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+
+		limit, ok := queryInt(w, r, "limit", defaultPageSize)
+		if !ok {
+			return
+		}
+		if limit < 1 || limit > maxPageSize {
+			limit = maxPageSize
+		}
+
+		handler := formHandler
+		if name := r.URL.Query().Get("tenant"); name != "" {
+			handler = nil
+			for _, t := range tenants {
+				if t.Name == name {
+					handler = t.handler
+				}
+			}
+
+			if handler == nil {
+				http.Error(w, "Unknown tenant", http.StatusNotFound)
+				return
+			}
+		}
+
+		respond(w, r, http.StatusOK, handler.Recent(limit))
+	}
+}
+
+// pagination reads ?limit= and ?offset=, capping the limit at maxPageSize
+func pagination(w http.ResponseWriter, r *http.Request) (limit int, offset int, ok bool) {
+	if limit, ok = queryInt(w, r, "limit", defaultPageSize); !ok {
+		return
+	}
+	if offset, ok = queryInt(w, r, "offset", 0); !ok {
+		return
+	}
+
+	if limit < 1 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	return
+}
+
+// queryInt reads an integer query parameter, responding with 400 when it is invalid
+func queryInt(w http.ResponseWriter, r *http.Request, key string, def int) (int, bool) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"key":   key,
+			"value": value,
+		})).Error("Invalid query parameter")
+		http.Error(w, "Invalid "+key, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return parsed, true
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+func TestClubsCountsTeamsPerClub(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	ctx := context.Background()
+	for i, registration := range []form.Registration{
+		{Club: "SBC2000", Year: 2018, Teams: make([]form.Team, 3)},
+		{Club: "Kinheim", Year: 2018, Teams: make([]form.Team, 1)},
+		{Club: "SBC2000", Year: 2018, Teams: make([]form.Team, 2)},
+		{Club: "Huizen", Year: 2017, Teams: make([]form.Team, 4)},
+	} {
+		if _, err := store.SaveRegistration(ctx, registration, strconv.Itoa(i+1), "NL", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/clubs?year=2018", nil)
+	rec := httptest.NewRecorder()
+	clubsHandler(store, form.Config{})(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp struct {
+		Items []form.ClubSummary `json:"items"`
+		Total int                `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+
+	expected := []form.ClubSummary{{Club: "Kinheim", Teams: 1}, {Club: "SBC2000", Teams: 5}}
+	if resp.Total != len(expected) || len(resp.Items) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, resp)
+	}
+	for i, club := range expected {
+		if resp.Items[i] != club {
+			t.Errorf("expected %+v, got %+v", club, resp.Items[i])
+		}
+	}
+}
+
+func TestClubsDefaultsToTheSeasonYear(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	registration := form.Registration{Club: "SBC2000", Year: 2018, Teams: make([]form.Team, 2)}
+	if _, err := store.SaveRegistration(context.Background(), registration, "1", "NL", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/clubs", nil)
+	rec := httptest.NewRecorder()
+	clubsHandler(store, form.Config{SeasonYear: 2018})(rec, r)
+
+	var resp struct {
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if resp.Total != 1 {
+		t.Errorf("expected the club of season 2018, got %d clubs", resp.Total)
+	}
+}
+
+func TestSeasonYearUsesTheLocationOfTheHandler(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	newYear := time.Date(2018, 12, 31, 23, 30, 0, 0, time.UTC)
+	if year := seasonYear(form.Config{Location: amsterdam}, newYear); year != 2019 {
+		t.Errorf("expected 2019 in Amsterdam, got %d", year)
+	}
+	if year := seasonYear(form.Config{}, newYear); year != 2018 {
+		t.Errorf("expected 2018 in UTC, got %d", year)
+	}
+	if year := seasonYear(form.Config{SeasonYear: 2017, Location: amsterdam}, newYear); year != 2017 {
+		t.Errorf("expected the configured season 2017, got %d", year)
+	}
+}
+
+func TestClubsRejectsInvalidYear(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/clubs?year=last", nil)
+	rec := httptest.NewRecorder()
+	clubsHandler(formtest.NewMemoryStore(), form.Config{})(rec, r)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestClubsPagination(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	ctx := context.Background()
+	clubs := []string{"Huizen", "Kinheim", "Lycurgus", "SBC2000", "Zaanstad"}
+	for i, club := range clubs {
+		registration := form.Registration{Club: club, Year: 2018, Teams: make([]form.Team, 1)}
+		if _, err := store.SaveRegistration(ctx, registration, strconv.Itoa(i+1), "NL", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		clubs  []string
+		limit  int
+		offset int
+	}{
+		{"limit=2", clubs[:2], 2, 0},
+		{"limit=2&offset=2", clubs[2:4], 2, 2},
+		{"limit=2&offset=4", clubs[4:], 2, 4},
+		{"limit=2&offset=10", nil, 2, 10},
+		{"", clubs, defaultPageSize, 0},
+		{"limit=1000&offset=-1", clubs, maxPageSize, 0},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/clubs?year=2018&"+test.query, nil)
+		rec := httptest.NewRecorder()
+		clubsHandler(store, form.Config{})(rec, r)
+
+		var resp struct {
+			Items  []form.ClubSummary `json:"items"`
+			Total  int                `json:"total"`
+			Limit  int                `json:"limit"`
+			Offset int                `json:"offset"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: expected a JSON response: %v", test.query, err)
+		}
+
+		var names []string
+		for _, club := range resp.Items {
+			names = append(names, club.Club)
+		}
+		if strings.Join(names, ",") != strings.Join(test.clubs, ",") {
+			t.Errorf("%s: expected %v, got %v", test.query, test.clubs, names)
+		}
+		if resp.Total != len(clubs) || resp.Limit != test.limit || resp.Offset != test.offset {
+			t.Errorf("%s: expected total %d, limit %d and offset %d, got %+v",
+				test.query, len(clubs), test.limit, test.offset, resp)
+		}
+	}
+}
+
+func TestAuditListsRecentSubmissions(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	post(hook, validBody)
+	post(hook, strings.Replace(validBody, "jan@example.com", "jan at example", 1))
+	post(hook, strings.Replace(validBody, "Inschrijven teams", "Contact", 1))
+
+	rec := request(auditHandler(formHandler, nil), http.MethodGet, "/audit?limit=10", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var entries []form.AuditEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("expected a JSON list: %v", err)
+	}
+
+	var outcomes []string
+	for _, entry := range entries {
+		outcomes = append(outcomes, entry.Outcome+":"+entry.Error)
+	}
+	if expected := "ignored:, failed:invalid, stored:"; strings.Join(outcomes, ", ") != expected {
+		t.Errorf("expected %s, newest first, got %v", expected, outcomes)
+	}
+	if len(entries) == 3 && entries[2].SubscriptionID == "" {
+		t.Error("expected the stored submission to have its subscription ID")
+	}
+	if strings.Contains(rec.Body.String(), "jan at example") {
+		t.Error("expected no contact details in the audit log")
+	}
+
+	if rec = request(auditHandler(formHandler, nil), http.MethodGet, "/audit?limit=1", ""); strings.Count(rec.Body.String(), "outcome") != 1 {
+		t.Errorf("expected a single entry with limit=1, got %s", rec.Body)
+	}
+	if rec = request(auditHandler(formHandler, nil), http.MethodGet, "/audit?tenant=other", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown tenant, got %d", rec.Code)
+	}
+}
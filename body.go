@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cappedReader reads at most max bytes of a request body and remembers whether the body was larger
+type cappedReader struct {
+	r    io.Reader
+	read int64
+	max  int64
+}
+
+func newCappedReader(r io.Reader, max int64) *cappedReader {
+	// one byte more than allowed is read to tell a body of exactly max bytes from a larger one
+	return &cappedReader{r: io.LimitReader(r, max+1), max: max}
+}
+
+func (c *cappedReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.read += int64(n)
+	if c.exceeded() {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return
+}
+
+// exceeded reports whether the body turned out to be larger than allowed
+func (c *cappedReader) exceeded() bool {
+	return c.read > c.max
+}
+
+func tooLarge(w http.ResponseWriter, max int64) {
+	log.WithField("max", max).Error("Request body too large")
+	http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+}
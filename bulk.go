@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+type bulkResult struct {
+	form.Result
+	Error string `json:"error,omitempty"`
+}
+
+// bulkHandler handles a JSON array of messages, each independently of the others. The array is
+// decoded completely before any message is handled, so a malformed body stores nothing.
+// Every message is decoded like a /hook body, strictFields rejects unknown fields.
+func bulkHandler(formHandler form.Handler, maxBytes int64, strictFields bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+
+		defer r.Body.Close()
+		body := newCappedReader(r.Body, maxBytes)
+
+		msgs, err := decodeMessages(body, strictFields)
+		if body.exceeded() {
+			tooLarge(w, maxBytes)
+			return
+		}
+		if err != nil {
+			log.WithField("error", err).Error("Cannot parse body")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results := make([]bulkResult, len(msgs))
+		for i, msg := range msgs {
+			msg.Source = requestSource(r)
+			if results[i].Result, err = formHandler.Handle(r.Context(), msg); err != nil {
+				results[i].Error = err.Error()
+			}
+		}
+
+		log.WithField("messages", len(msgs)).Info("Handled bulk import")
+
+		writeJSON(w, http.StatusOK, results)
+	}
+}
+
+// decodeMessages streams the messages of a JSON array, stopping at the first malformed one
+func decodeMessages(body io.Reader, strict bool) (msgs []form.Message, err error) {
+	decoder := json.NewDecoder(body)
+
+	var token json.Token
+	if token, err = decoder.Token(); err != nil {
+		return
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("Body must be an array of messages")
+	}
+
+	for decoder.More() {
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("Message %d: %v", len(msgs)+1, err)
+		}
+
+		var msg form.Message
+		if msg, err = form.DecodeMessage(bytes.NewReader(raw), strict); err != nil {
+			return nil, fmt.Errorf("Message %d: %v", len(msgs)+1, err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	// the closing bracket, a truncated body fails here
+	if _, err = decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestBulkReportsEveryItem(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	other := strings.Replace(validBody, `"contact-club": "SBC2000"`, `"contact-club": "Kinheim"`, 1)
+	body := "[" + validBody + `, {"title": "Inschrijven teams", "posted_data": {"contact-club": "Huizen"}}, ` + other + "]"
+
+	r := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	bulkHandler(formHandler, 1<<20, false)(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var results []bulkResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected a result per item, got %d", len(results))
+	}
+
+	for i, valid := range []bool{true, false, true} {
+		if stored := results[i].SubscriptionID != "" && results[i].Error == ""; stored != valid {
+			t.Errorf("item %d: expected stored to be %t, got %+v", i, valid, results[i])
+		}
+	}
+	if store.Registrations() != 2 {
+		t.Errorf("expected the valid items to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestBulkStoresNothingForMalformedBody(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader("["+validBody+", {"))
+	rec := httptest.NewRecorder()
+	bulkHandler(formHandler, 1<<20, false)(rec, r)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestBulkRejectsBodyOverLimit(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	body := "[" + validBody + "]"
+	r := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	bulkHandler(formHandler, int64(len(body)-1), false)(rec, r)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestBulkDecodesMessagesLikeTheHook(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	list := strings.Replace(validBody, `"team1-type": "Heren"`, `"team1-type": ["Heren"]`, 1)
+	r := httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader("["+list+"]"))
+	rec := httptest.NewRecorder()
+	bulkHandler(formHandler, 1<<20, false)(rec, r)
+
+	if rec.Code != http.StatusOK || store.Registrations() != 1 {
+		t.Errorf("expected a list value to be accepted, got %d and %d registrations", rec.Code, store.Registrations())
+	}
+
+	unknown := strings.Replace(validBody, `"title":`, `"unknown": true, "title":`, 1)
+	r = httptest.NewRequest(http.MethodPost, "/bulk", strings.NewReader("["+unknown+"]"))
+	rec = httptest.NewRecorder()
+	bulkHandler(formHandler, 1<<20, true)(rec, r)
+
+	if rec.Code != http.StatusBadRequest || store.Registrations() != 1 {
+		t.Errorf("expected an unknown field to be rejected with strict fields, got %d and %d registrations", rec.Code, store.Registrations())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// checkDuplicateKeys returns an error when any object in the JSON document repeats a key,
+// which encoding/json silently resolves by keeping the last value
+func checkDuplicateKeys(buffer []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(buffer))
+
+	// one entry per open object or array, nil for arrays
+	var (
+		stack     []map[string]struct{}
+		expectKey []bool
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if len(stack) == 0 {
+				return nil
+			}
+			return err
+		}
+
+		inObject := len(stack) > 0 && stack[len(stack)-1] != nil
+		if key, ok := token.(string); ok && inObject && expectKey[len(expectKey)-1] {
+			if _, exists := stack[len(stack)-1][key]; exists {
+				return fmt.Errorf("Duplicate key: %s", key)
+			}
+			stack[len(stack)-1][key] = struct{}{}
+			expectKey[len(expectKey)-1] = false
+			continue
+		}
+
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, make(map[string]struct{}))
+			expectKey = append(expectKey, true)
+			continue
+		case json.Delim('['):
+			stack = append(stack, nil)
+			expectKey = append(expectKey, false)
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			expectKey = expectKey[:len(expectKey)-1]
+		}
+
+		// a value completes an entry of the enclosing object
+		if len(stack) > 0 && stack[len(stack)-1] != nil {
+			expectKey[len(expectKey)-1] = true
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Features are the switches enabled together through FEATURES, e.g. "strict-json,redact-pii".
+// A flag only changes the default, the environment variable of the same setting still wins.
+type Features struct {
+	StrictJSON         bool
+	StrictFields       bool
+	StrictSeason       bool
+	StrictPoules       bool
+	StrictAvailability bool
+	StrictTeamSlots    bool
+	ContiguousTeams    bool
+	UniqueTeamNames    bool
+	SortTeams          bool
+	RedactPII          bool
+	TrustProxy         bool
+	RunMigrations      bool
+}
+
+// features is read once on startup, see parseFeatures
+var features Features
+
+// flags maps the names used in FEATURES to the switches they enable
+func (f *Features) flags() map[string]*bool {
+	return map[string]*bool{
+		"strict-json":         &f.StrictJSON,
+		"strict-fields":       &f.StrictFields,
+		"strict-season":       &f.StrictSeason,
+		"strict-poules":       &f.StrictPoules,
+		"strict-availability": &f.StrictAvailability,
+		"strict-team-slots":   &f.StrictTeamSlots,
+		"contiguous-teams":    &f.ContiguousTeams,
+		"unique-team-names":   &f.UniqueTeamNames,
+		"sort-teams":          &f.SortTeams,
+		"redact-pii":          &f.RedactPII,
+		"trust-proxy":         &f.TrustProxy,
+		"run-migrations":      &f.RunMigrations,
+	}
+}
+
+// parseFeatures enables the comma separated flags in value, unknown flags are logged and ignored
+func parseFeatures(value string) (f Features) {
+	flags := f.flags()
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+
+		flag, ok := flags[name]
+		if !ok {
+			log.WithField("feature", name).Warn("Ignoring unknown feature")
+			continue
+		}
+		*flag = true
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseFeatures(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Features
+	}{
+		{"", Features{}},
+		{"strict-json", Features{StrictJSON: true}},
+		{" Strict-JSON , redact-pii,,sort-teams ", Features{StrictJSON: true, RedactPII: true, SortTeams: true}},
+		{"trust-proxy,no-such-flag", Features{TrustProxy: true}},
+	}
+
+	for _, test := range tests {
+		if f := parseFeatures(test.value); f != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.value, test.expected, f)
+		}
+	}
+}
+
+func TestParseFeaturesWarnsAboutUnknownFlags(t *testing.T) {
+	logs := capture()
+
+	parseFeatures("strict-json,strikt-fields")
+	if !logs.contains("strikt-fields") {
+		t.Error("expected a warning naming the unknown flag")
+	}
+	if logs.contains("strict-json") {
+		t.Error("expected no warning for a known flag")
+	}
+}
+
+func TestFeaturesAreOverriddenByEnvironment(t *testing.T) {
+	f := parseFeatures("strict-json")
+
+	os.Unsetenv("STRICT_JSON")
+	if !envBool("STRICT_JSON", f.StrictJSON) {
+		t.Error("expected the strict-json flag to apply without STRICT_JSON")
+	}
+
+	os.Setenv("STRICT_JSON", "false")
+	defer os.Unsetenv("STRICT_JSON")
+
+	if envBool("STRICT_JSON", f.StrictJSON) {
+		t.Error("expected STRICT_JSON=false to disable the strict-json flag")
+	}
+}
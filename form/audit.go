@@ -0,0 +1,130 @@
+package form
+
+import (
+	"sync"
+	"time"
+)
+
+// outcomes of a submission in the audit log
+const (
+	outcomeStored    = "stored"
+	outcomeQueued    = "queued"
+	outcomeDuplicate = "duplicate"
+	outcomeIgnored   = "ignored"
+	outcomeFailed    = "failed"
+)
+
+// AuditEntry describes how a submission was handled, without its contact details
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Title string    `json:"title"`
+	// Outcome is stored, queued, duplicate, ignored or failed
+	Outcome        string `json:"outcome"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	// Error classifies a failure, such as invalid or transient, the error text itself may contain
+	// contact details
+	Error string `json:"error,omitempty"`
+}
+
+// auditLog keeps the most recent entries in a ring buffer, older entries are overwritten
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	// next is the position the next entry is written to
+	next int
+	full bool
+}
+
+func newAuditLog(size int) *auditLog {
+	return &auditLog{entries: make([]AuditEntry, size)}
+}
+
+func (a *auditLog) add(entry AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[a.next] = entry
+	a.next = (a.next + 1) % len(a.entries)
+	if a.next == 0 {
+		a.full = true
+	}
+}
+
+// recent returns up to n entries, the newest first
+func (a *auditLog) recent(n int) []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	size := a.next
+	if a.full {
+		size = len(a.entries)
+	}
+	if n > size || n < 0 {
+		n = size
+	}
+
+	recent := make([]AuditEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, a.entries[(a.next-i+len(a.entries))%len(a.entries)])
+	}
+
+	return recent
+}
+
+// record adds the outcome of a submission to the audit log, an empty outcome is derived from
+// the result and error
+func (h *handler) record(message Message, outcome string, result Result, err error) {
+	entry := AuditEntry{
+		Time:    h.clock.Now(),
+		Title:   message.Title,
+		Outcome: outcome,
+		Error:   errorCode(err),
+	}
+
+	// the ID of a decoy result for spam was never stored
+	if outcome != outcomeIgnored {
+		entry.SubscriptionID = result.SubscriptionID
+	}
+
+	if entry.Outcome == "" {
+		switch {
+		case err != nil:
+			entry.Outcome = outcomeFailed
+		case result.Queued:
+			entry.Outcome = outcomeQueued
+		default:
+			entry.Outcome = outcomeStored
+		}
+	}
+
+	h.audit.add(entry)
+}
+
+// errorCode classifies err for the audit log, empty for no error
+func errorCode(err error) string {
+	switch err.(type) {
+	case nil:
+		return ""
+	case ValidationErrors:
+		return "invalid"
+	case TransientError:
+		return "transient"
+	case TooSoonError:
+		return "too-soon"
+	}
+
+	switch err {
+	case ErrDuplicate:
+		return "duplicate"
+	case ErrSeasonFull:
+		return "season-full"
+	case ErrQueueFull:
+		return "queue-full"
+	}
+
+	return "internal"
+}
+
+func (h *handler) Recent(n int) []AuditEntry {
+	return h.audit.recent(n)
+}
@@ -0,0 +1,48 @@
+package form
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAuditLogKeepsNewestEntries(t *testing.T) {
+	audit := newAuditLog(3)
+	if recent := audit.recent(10); len(recent) != 0 {
+		t.Errorf("expected an empty log, got %v", recent)
+	}
+
+	for _, id := range []string{"000001", "000002", "000003", "000004"} {
+		audit.add(AuditEntry{SubscriptionID: id})
+	}
+
+	var ids []string
+	for _, entry := range audit.recent(10) {
+		ids = append(ids, entry.SubscriptionID)
+	}
+	if len(ids) != 3 || ids[0] != "000004" || ids[2] != "000002" {
+		t.Errorf("expected 000004 to 000002, newest first, got %v", ids)
+	}
+
+	if recent := audit.recent(1); len(recent) != 1 || recent[0].SubscriptionID != "000004" {
+		t.Errorf("expected only the newest entry, got %v", recent)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{nil, ""},
+		{ValidationErrors{"jan@example is not a valid email address"}, "invalid"},
+		{TransientError{errors.New("connection reset")}, "transient"},
+		{ErrSeasonFull, "season-full"},
+		{errors.New("pq: duplicate key value violates unique constraint"), "internal"},
+	}
+
+	for _, test := range tests {
+		if code := errorCode(test.err); code != test.code {
+			t.Errorf("%v: expected %q, got %q", test.err, test.code, code)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package form
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedStore keeps recently read registrations in memory, so lookups of the same subscription do
+// not all reach the database. Saved registrations are not cached as written, the database
+// normalizes them, e.g. trims them to the width of their columns.
+type cachedStore struct {
+	Store
+	clock   Clock
+	ttl     time.Duration
+	size    int
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	form     Registration
+	language Language
+	at       time.Time
+}
+
+// NewCachedStore caches up to size registrations of store for ttl, evicting the oldest first
+func NewCachedStore(store Store, ttl time.Duration, size int) Store {
+	return NewCachedStoreWith(store, systemClock{}, ttl, size)
+}
+
+// NewCachedStoreWith creates a cached store that tells the age of its entries with clock
+func NewCachedStoreWith(store Store, clock Clock, ttl time.Duration, size int) Store {
+	return &cachedStore{
+		Store:   store,
+		clock:   clock,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *cachedStore) Registration(ctx context.Context, subscriptionID string) (form Registration, language Language, err error) {
+	if entry, ok := c.get(subscriptionID); ok {
+		return entry.form, entry.language, nil
+	}
+
+	if form, language, err = c.Store.Registration(ctx, subscriptionID); err == nil {
+		c.put(subscriptionID, form, language)
+	}
+
+	return
+}
+
+// AddTeam removes the registration from the cache before and after the write, so a lookup during
+// the write cannot keep the registration without the team cached for the TTL
+func (c *cachedStore) AddTeam(ctx context.Context, subscriptionID string, team Team, maxTeams int, maxOfType int) (int, error) {
+	c.remove(subscriptionID)
+	defer c.remove(subscriptionID)
+
+	return c.Store.AddTeam(ctx, subscriptionID, team, maxTeams, maxOfType)
+}
+
+func (c *cachedStore) ChangeSubscriptionID(ctx context.Context, oldID string, newID string) error {
+	c.remove(oldID)
+	defer c.remove(oldID)
+
+	return c.Store.ChangeSubscriptionID(ctx, oldID, newID)
+}
+
+func (c *cachedStore) DeleteRegistration(ctx context.Context, subscriptionID string) error {
+	c.remove(subscriptionID)
+	defer c.remove(subscriptionID)
+
+	return c.Store.DeleteRegistration(ctx, subscriptionID)
+}
+
+func (c *cachedStore) get(subscriptionID string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[subscriptionID]
+	if ok && c.clock.Now().Sub(entry.at) > c.ttl {
+		delete(c.entries, subscriptionID)
+		return entry, false
+	}
+
+	return entry, ok
+}
+
+func (c *cachedStore) put(subscriptionID string, form Registration, language Language) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[subscriptionID]; !ok && len(c.entries) >= c.size {
+		c.evictOldest()
+	}
+
+	c.entries[subscriptionID] = cacheEntry{form, language, c.clock.Now()}
+}
+
+// evictOldest drops the entry cached the longest ago, the cache is small enough to scan
+func (c *cachedStore) evictOldest() {
+	var (
+		oldest string
+		at     time.Time
+	)
+	for subscriptionID, entry := range c.entries {
+		if oldest == "" || entry.at.Before(at) {
+			oldest, at = subscriptionID, entry.at
+		}
+	}
+
+	delete(c.entries, oldest)
+}
+
+func (c *cachedStore) remove(subscriptionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, subscriptionID)
+}
@@ -0,0 +1,145 @@
+package form_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+// readCountingStore counts the registrations read from the underlying store
+type readCountingStore struct {
+	*formtest.MemoryStore
+	reads int
+	// duringAddTeam is called before the team is written, e.g. to look the registration up
+	duringAddTeam func()
+}
+
+func (s *readCountingStore) Registration(ctx context.Context, subscriptionID string) (form.Registration, form.Language, error) {
+	s.reads++
+	return s.MemoryStore.Registration(ctx, subscriptionID)
+}
+
+func (s *readCountingStore) AddTeam(ctx context.Context, subscriptionID string, team form.Team, maxTeams int, maxOfType int) (int, error) {
+	if s.duringAddTeam != nil {
+		s.duringAddTeam()
+	}
+	return s.MemoryStore.AddTeam(ctx, subscriptionID, team, maxTeams, maxOfType)
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	backing := &readCountingStore{MemoryStore: formtest.NewMemoryStore()}
+	clock := &formtest.Clock{T: submitTime}
+	store := form.NewCachedStoreWith(backing, clock, time.Minute, 2)
+
+	registration := form.Registration{Year: 2018, Club: "SBC2000", Teams: []form.Team{{Slot: 1, Name: "Heren 1"}}}
+	if _, err := store.SaveRegistration(ctx, registration, "000001", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// saved registrations are not cached, the first read is a miss and the second a hit
+	for i := 0; i < 2; i++ {
+		if cached, _, err := store.Registration(ctx, "000001"); err != nil || cached.Club != "SBC2000" {
+			t.Fatalf("expected the saved registration, got %+v and %v", cached, err)
+		}
+	}
+	if backing.reads != 1 {
+		t.Errorf("expected a miss and a hit, the store was read %d times", backing.reads)
+	}
+
+	// expired entries are read again and cached once more
+	clock.Advance(2 * time.Minute)
+	store.Registration(ctx, "000001")
+	store.Registration(ctx, "000001")
+	if backing.reads != 2 {
+		t.Errorf("expected one miss after the TTL, the store was read %d times", backing.reads)
+	}
+
+	if _, err := store.AddTeam(ctx, "000001", form.Team{Name: "Heren 2"}, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _, _ := store.Registration(ctx, "000001"); len(cached.Teams) != 2 || backing.reads != 3 {
+		t.Errorf("expected the added team to invalidate the entry, got %d teams after %d reads", len(cached.Teams), backing.reads)
+	}
+
+	if err := store.ChangeSubscriptionID(ctx, "000001", "000002"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Registration(ctx, "000001"); err != form.ErrSubscriptionNotFound {
+		t.Errorf("expected the old ID to be gone after regenerating it, got %v", err)
+	}
+
+	if err := store.DeleteRegistration(ctx, "000002"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.Registration(ctx, "000002"); err != form.ErrSubscriptionNotFound {
+		t.Errorf("expected a deleted registration to be gone, got %v", err)
+	}
+}
+
+func TestCachedStoreEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	backing := &readCountingStore{MemoryStore: formtest.NewMemoryStore()}
+	clock := &formtest.Clock{T: submitTime}
+	store := form.NewCachedStoreWith(backing, clock, time.Hour, 2)
+
+	for _, subscriptionID := range []string{"000001", "000002", "000003"} {
+		if _, err := store.SaveRegistration(ctx, form.Registration{Year: 2018}, subscriptionID, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		store.Registration(ctx, subscriptionID)
+		clock.Advance(time.Second)
+	}
+
+	store.Registration(ctx, "000003")
+	store.Registration(ctx, "000002")
+	if backing.reads != 3 {
+		t.Errorf("expected the newest two to be cached, the store was read %d times", backing.reads)
+	}
+
+	store.Registration(ctx, "000001")
+	if backing.reads != 4 {
+		t.Errorf("expected the oldest to be evicted, the store was read %d times", backing.reads)
+	}
+}
+
+func TestCachedStoreForgetsLookupsDuringAWrite(t *testing.T) {
+	ctx := context.Background()
+	backing := &readCountingStore{MemoryStore: formtest.NewMemoryStore()}
+	store := form.NewCachedStoreWith(backing, &formtest.Clock{T: submitTime}, time.Hour, 2)
+
+	registration := form.Registration{Year: 2018, Teams: []form.Team{{Slot: 1, Name: "Heren 1"}}}
+	if _, err := store.SaveRegistration(ctx, registration, "000001", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// another request looks the registration up while the team is being added
+	backing.duringAddTeam = func() { store.Registration(ctx, "000001") }
+	if _, err := store.AddTeam(ctx, "000001", form.Team{Name: "Heren 2"}, 5, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if cached, _, _ := store.Registration(ctx, "000001"); len(cached.Teams) != 2 {
+		t.Errorf("expected the registration with the added team, got %d teams", len(cached.Teams))
+	}
+}
+
+func TestCachedStoreCachesTheStoredValues(t *testing.T) {
+	ctx := context.Background()
+	backing := &readCountingStore{MemoryStore: formtest.NewMemoryStore()}
+	store := form.NewCachedStoreWith(backing, &formtest.Clock{T: submitTime}, time.Hour, 2)
+
+	if _, err := store.SaveRegistration(ctx, form.Registration{Year: 2018, Club: " SBC2000 "}, "000001", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	// the database trims what it stores, which the memory store leaves to its caller
+	backing.MemoryStore.DeleteRegistration(ctx, "000001")
+	backing.MemoryStore.SaveRegistration(ctx, form.Registration{Year: 2018, Club: "SBC2000"}, "000001", "", 0)
+
+	if cached, _, _ := store.Registration(ctx, "000001"); cached.Club != "SBC2000" {
+		t.Errorf("expected the stored club, got %q", cached.Club)
+	}
+}
@@ -0,0 +1,49 @@
+package form
+
+import (
+	"bytes"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// callbackPayload is posted to the callback URL once a registration is stored
+type callbackPayload struct {
+	SubscriptionID string `json:"subscriptionId"`
+	Teams          int    `json:"teams"`
+	Reference      string `json:"reference,omitempty"`
+}
+
+// callback tells wordpress the subscription ID in the background, failures are only logged
+// since the registration itself is stored
+func (h *handler) callback(result Result, reference string) {
+	body, err := json.Marshal(callbackPayload{result.SubscriptionID, result.Teams, reference})
+	if err != nil {
+		h.config.Logger.WithField("error", err).Error("Failed to encode callback")
+		return
+	}
+
+	h.callbacks.Add(1)
+	go func() {
+		defer h.callbacks.Done()
+
+		fields := log.Fields(map[string]interface{}{
+			"url":            h.config.CallbackURL,
+			"subscriptionID": result.SubscriptionID,
+		})
+
+		resp, err := h.config.HTTPClient.Post(h.config.CallbackURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			h.config.Logger.WithFields(fields).WithField("error", err).Error("Failed to send callback")
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			h.config.Logger.WithFields(fields).WithField("status", resp.StatusCode).Error("Callback was rejected")
+			return
+		}
+
+		h.config.Logger.WithFields(fields).Info("Sent callback")
+	}()
+}
@@ -0,0 +1,86 @@
+package form_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+// callbackServer records the bodies posted to it and answers with status
+func callbackServer(status int) (*httptest.Server, func() []map[string]interface{}) {
+	var (
+		mu     sync.Mutex
+		bodies []map[string]interface{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+
+	return server, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies
+	}
+}
+
+func TestCallback(t *testing.T) {
+	server, received := callbackServer(http.StatusOK)
+	defer server.Close()
+
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{CallbackURL: server.URL})
+
+	message := validMessage()
+	message.Data["_wpcf7_unit_tag"] = "wpcf7-f12-p34-o1"
+	result, err := h.Handle(context.Background(), message)
+	// waits for the callbacks
+	h.Close()
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	bodies := received()
+	if len(bodies) != 1 {
+		t.Fatalf("expected a single callback, got %d", len(bodies))
+	}
+	expected := map[string]interface{}{
+		"subscriptionId": result.SubscriptionID,
+		"teams":          float64(1),
+		"reference":      "wpcf7-f12-p34-o1",
+	}
+	for key, value := range expected {
+		if bodies[0][key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, bodies[0][key])
+		}
+	}
+}
+
+func TestCallbackFailureIsNotFatal(t *testing.T) {
+	server, received := callbackServer(http.StatusInternalServerError)
+	defer server.Close()
+
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{CallbackURL: server.URL})
+
+	_, err := h.Handle(context.Background(), validMessage())
+	h.Close()
+	if err != nil {
+		t.Errorf("expected a rejected callback not to fail the submission, got %v", err)
+	}
+	if len(received()) != 1 || store.Registrations() != 1 {
+		t.Errorf("expected the registration to be stored and the callback to be sent")
+	}
+}
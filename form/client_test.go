@@ -0,0 +1,29 @@
+package form_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestHTTPClientTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	started := time.Now()
+	resp, err := form.NewHTTPClient(50 * time.Millisecond).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the request to a hung server to time out")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("expected the request to give up after the timeout, it took %v", elapsed)
+	}
+}
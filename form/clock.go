@@ -0,0 +1,14 @@
+package form
+
+import "time"
+
+// Clock tells the time, tests replace it to control submit times and the duplicate window
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,85 @@
+package form
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// canonicalClub maps a possibly misspelled club name to the closest name of clubs.
+// The submitted name is kept when no club is close enough or several are equally close.
+func canonicalClub(club string, clubs []string, maxDistance int) string {
+	if len(clubs) == 0 || club == "" {
+		return club
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(club))
+
+	var (
+		best     string
+		bestDist = maxDistance + 1
+		tied     bool
+	)
+	for _, candidate := range clubs {
+		dist := levenshtein(normalized, strings.ToLower(candidate))
+		if dist < bestDist {
+			best, bestDist, tied = candidate, dist, false
+		} else if dist == bestDist && candidate != best {
+			tied = true
+		}
+	}
+
+	switch {
+	case bestDist > maxDistance:
+		log.WithField("club", club).Warn("Unknown club, storing as submitted")
+		return club
+	case tied:
+		log.WithFields(log.Fields(map[string]interface{}{
+			"club":     club,
+			"distance": bestDist,
+		})).Warn("Club matches several known clubs, storing as submitted")
+		return club
+	case bestDist > 0:
+		log.WithFields(log.Fields(map[string]interface{}{
+			"club":      club,
+			"canonical": best,
+		})).Info("Corrected club name")
+	}
+
+	return best
+}
+
+// levenshtein counts the single character edits needed to turn a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,43 @@
+package form
+
+import "testing"
+
+func TestCanonicalClub(t *testing.T) {
+	clubs := []string{"SBC2000", "Hoofddorp", "Huizen", "Kinheim"}
+
+	tests := []struct {
+		submitted string
+		expected  string
+	}{
+		// exact, apart from case and whitespace
+		{"SBC2000", "SBC2000"},
+		{" sbc2000 ", "SBC2000"},
+		// near
+		{"SBC 2000", "SBC2000"},
+		{"Hofddorp", "Hoofddorp"},
+		{"Kinhiem", "Kinheim"},
+		// no match
+		{"Amsterdam", "Amsterdam"},
+		{"Huizem", "Huizen"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if club := canonicalClub(test.submitted, clubs, 2); club != test.expected {
+			t.Errorf("expected %q to be stored as %q, got %q", test.submitted, test.expected, club)
+		}
+	}
+}
+
+func TestCanonicalClubAmbiguous(t *testing.T) {
+	// Kampen and Kapmen are both a single edit away from Kamen
+	if club := canonicalClub("Kamen", []string{"Kampen", "Kapmen"}, 2); club != "Kamen" {
+		t.Errorf("expected an ambiguous club to be stored as submitted, got %q", club)
+	}
+}
+
+func TestCanonicalClubDisabled(t *testing.T) {
+	if club := canonicalClub("SBC 2000", nil, 2); club != "SBC 2000" {
+		t.Errorf("expected the club as submitted without known clubs, got %q", club)
+	}
+}
@@ -0,0 +1,77 @@
+package form
+
+import (
+	"context"
+	"html/template"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// confirmationTemplate renders a subscription for printing, html/template escapes every value
+var confirmationTemplate = template.Must(template.New("confirmation").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.3em 0.6em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+<dt>{{.Labels.Club}}</dt><dd>{{.Form.Club}}</dd>
+<dt>{{.Labels.Contact}}</dt><dd>{{.Form.Name}} {{.Form.Surname}}<br>{{.Form.Email}}<br>{{.Form.Phone}}</dd>
+{{with .Form.SecondContact}}<dt>{{$.Labels.SecondContact}}</dt><dd>{{.Name}} {{.Surname}}<br>{{.Email}}<br>{{.Phone}}</dd>{{end}}
+<dt>{{.Labels.Submitted}}</dt><dd>{{.Form.SubmitTime.Format "02-01-2006 15:04"}}</dd>
+{{if .Form.Notes}}<dt>{{.Labels.Notes}}</dt><dd>{{.Form.Notes}}</dd>{{end}}
+</dl>
+<h2>{{.Labels.Teams}}</h2>
+<table>
+{{range .Form.Teams}}<tr><td>{{.Slot}}</td><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Level}}</td><td>{{.Poule}}</td><td>{{.Availability}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type confirmationLabels struct {
+	Contact       string
+	SecondContact string
+	Club          string
+	Submitted     string
+	Teams         string
+	Notes         string
+}
+
+func (h *handler) Confirmation(ctx context.Context, subscriptionID string, w io.Writer) (err error) {
+	var (
+		form Registration
+		lang Language
+	)
+	if form, lang, err = h.store.Registration(ctx, subscriptionID); err != nil {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"error":          err,
+			"subscriptionID": subscriptionID,
+		})).Error("Failed to load subscription")
+		return
+	}
+
+	form.SubmitTime = form.SubmitTime.In(h.config.Location)
+
+	return confirmationTemplate.Execute(w, map[string]interface{}{
+		"Lang":  lang.Code(),
+		"Title": localize(lang, labelConfirmation, subscriptionID),
+		"Form":  form,
+		"Labels": confirmationLabels{
+			Contact:       localize(lang, labelContact),
+			SecondContact: localize(lang, labelSecondContact),
+			Club:          localize(lang, labelClub),
+			Submitted:     localize(lang, labelSubmitted),
+			Teams:         localize(lang, labelTeams),
+			Notes:         localize(lang, labelNotes),
+		},
+	})
+}
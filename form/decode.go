@@ -0,0 +1,89 @@
+package form
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// rawMessage is a Message before the types of its values are checked
+type rawMessage struct {
+	Title *json.RawMessage           `json:"title"`
+	Data  map[string]json.RawMessage `json:"posted_data"`
+}
+
+// DecodeMessage decodes and checks a submission, reporting every malformed field in ValidationErrors.
+// Non string values in posted_data are converted to their text, lists are joined with a comma.
+// With strict, fields other than title and posted_data are rejected as well.
+func DecodeMessage(body io.Reader, strict bool) (message Message, err error) {
+	decoder := json.NewDecoder(body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	var raw rawMessage
+	if err = decoder.Decode(&raw); err != nil {
+		return message, ValidationErrors{fmt.Sprintf("invalid message: %v", err)}
+	}
+
+	if _, err = decoder.Token(); err != io.EOF {
+		return message, ValidationErrors{"invalid message: unexpected data after the message"}
+	}
+
+	var problems ValidationErrors
+	if raw.Title == nil {
+		problems = append(problems, "title is required")
+	} else if err = json.Unmarshal(*raw.Title, &message.Title); err != nil {
+		problems = append(problems, "title must be a string")
+	}
+
+	if raw.Data != nil {
+		message.Data = make(map[string]string, len(raw.Data))
+	}
+	for key, value := range raw.Data {
+		text, ok := dataValue(value)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("posted_data.%s must be a string", key))
+			continue
+		}
+		message.Data[key] = text
+	}
+
+	return message, problems.err()
+}
+
+// dataValue converts a posted_data value to a string, accepting strings, numbers, booleans,
+// null and lists of those
+func dataValue(value json.RawMessage) (string, bool) {
+	var decoded interface{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return "", false
+	}
+
+	switch v := decoded.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	case float64, bool:
+		log.WithField("value", string(value)).Warn("Converting posted_data value to string")
+		return string(value), true
+	case []interface{}:
+		// checkbox fields post every checked option
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case string, float64, bool:
+				parts = append(parts, fmt.Sprint(item))
+			default:
+				return "", false
+			}
+		}
+		return strings.Join(parts, ", "), true
+	}
+
+	return "", false
+}
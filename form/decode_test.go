@@ -0,0 +1,54 @@
+package form_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestDecodeMessageConvertsValues(t *testing.T) {
+	message, err := form.DecodeMessage(strings.NewReader(`{"title": "Inschrijven teams", "posted_data": {
+		"contact-phone": 612345678, "contact-consent": true, "contact-notes": null,
+		"team1-availability": ["ma", "di"]}}`), false)
+	if err != nil {
+		t.Fatalf("expected the values to be converted, got %v", err)
+	}
+
+	expected := map[string]string{
+		"contact-phone":      "612345678",
+		"contact-consent":    "true",
+		"contact-notes":      "",
+		"team1-availability": "ma, di",
+	}
+	if !reflect.DeepEqual(message.Data, expected) {
+		t.Errorf("expected %v, got %v", expected, message.Data)
+	}
+}
+
+func TestDecodeMessageReportsEveryField(t *testing.T) {
+	_, err := form.DecodeMessage(strings.NewReader(`{"title": 1, "posted_data": {
+		"contact-name": {"first": "Jan"}, "team1-availability": [["ma"]]}}`), false)
+
+	problems, ok := err.(form.ValidationErrors)
+	if !ok || len(problems) != 3 {
+		t.Fatalf("expected the title and both values to be reported, got %v", err)
+	}
+	for _, field := range []string{"title", "posted_data.contact-name", "posted_data.team1-availability"} {
+		if !strings.Contains(strings.Join(problems, "\n"), field) {
+			t.Errorf("expected %s to be reported, got %q", field, problems)
+		}
+	}
+}
+
+func TestDecodeMessageExtraFields(t *testing.T) {
+	body := `{"title": "Inschrijven teams", "posted_data": {}, "form_id": 12}`
+
+	if _, err := form.DecodeMessage(strings.NewReader(body), false); err != nil {
+		t.Errorf("expected extra fields to be ignored by default, got %v", err)
+	}
+	if _, err := form.DecodeMessage(strings.NewReader(body), true); err == nil {
+		t.Error("expected extra fields to be rejected in strict mode")
+	}
+}
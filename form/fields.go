@@ -0,0 +1,83 @@
+package form
+
+// FieldMapping names the fields of the wordpress form, the team fields are
+// templates that receive the team number, e.g. "team%d-name"
+type FieldMapping struct {
+	Club    string
+	Name    string
+	Surname string
+	Email   string
+	Phone   string
+	// FullName is read when a form variant has a single field for the name and surname
+	FullName string
+	Notes    string
+	// Consent is the checkbox of the consent to data processing, read when a ConsentVersion is configured
+	Consent   string
+	TeamName  string
+	TeamType  string
+	TeamLevel string
+	TeamPoule string
+	// TeamAvailability holds the days a team prefers to play, as comma separated day codes
+	TeamAvailability string
+	// Reference identifies the submission in wordpress, it is sent back with the callback
+	Reference string
+	// SecondName, SecondSurname, SecondEmail and SecondPhone are the optional second contact person
+	SecondName    string
+	SecondSurname string
+	SecondEmail   string
+	SecondPhone   string
+}
+
+// DefaultFieldMapping returns the field names of the current wordpress form
+func DefaultFieldMapping() FieldMapping {
+	return FieldMapping{
+		Club:             "contact-club",
+		Name:             "contact-name",
+		Surname:          "contact-surname",
+		Email:            "contact-email",
+		Phone:            "contact-phone",
+		FullName:         "contact-fullname",
+		Notes:            "contact-notes",
+		Consent:          "contact-consent",
+		TeamName:         "team%d-name",
+		TeamType:         "team%d-type",
+		TeamLevel:        "team%d-level",
+		TeamPoule:        "team%d-poule",
+		TeamAvailability: "team%d-availability",
+		Reference:        "_wpcf7_unit_tag",
+		SecondName:       "contact2-name",
+		SecondSurname:    "contact2-surname",
+		SecondEmail:      "contact2-email",
+		SecondPhone:      "contact2-phone",
+	}
+}
+
+func (f FieldMapping) withDefaults() FieldMapping {
+	defaults := DefaultFieldMapping()
+	fallback := func(value *string, def string) {
+		if *value == "" {
+			*value = def
+		}
+	}
+
+	fallback(&f.Club, defaults.Club)
+	fallback(&f.Name, defaults.Name)
+	fallback(&f.Surname, defaults.Surname)
+	fallback(&f.Email, defaults.Email)
+	fallback(&f.Phone, defaults.Phone)
+	fallback(&f.FullName, defaults.FullName)
+	fallback(&f.Notes, defaults.Notes)
+	fallback(&f.Consent, defaults.Consent)
+	fallback(&f.TeamName, defaults.TeamName)
+	fallback(&f.TeamType, defaults.TeamType)
+	fallback(&f.TeamLevel, defaults.TeamLevel)
+	fallback(&f.TeamPoule, defaults.TeamPoule)
+	fallback(&f.TeamAvailability, defaults.TeamAvailability)
+	fallback(&f.Reference, defaults.Reference)
+	fallback(&f.SecondName, defaults.SecondName)
+	fallback(&f.SecondSurname, defaults.SecondSurname)
+	fallback(&f.SecondEmail, defaults.SecondEmail)
+	fallback(&f.SecondPhone, defaults.SecondPhone)
+
+	return f
+}
@@ -0,0 +1,53 @@
+package formtest_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+func Example() {
+	store := formtest.NewMemoryStore()
+	clock := &formtest.Clock{T: time.Date(2024, time.April, 1, 12, 0, 0, 0, time.UTC)}
+	spans := &formtest.Spans{}
+	logger, logs := formtest.NewLogger()
+
+	config := form.Config{SpanExporter: spans, Logger: logger}
+	h, err := form.NewHandlerWith(store, clock, &formtest.IDs{IDs: []string{"240001"}}, config)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer h.Close()
+
+	result, err := h.Handle(context.Background(), form.Message{
+		Title: "Sign up teams",
+		Data: map[string]string{
+			"contact-club":    "SBC2000",
+			"contact-name":    "Jan",
+			"contact-surname": "Jansen",
+			"contact-email":   "jan@example.com",
+			"contact-phone":   "0612345678",
+			"team1-name":      "Ladies 1",
+			"team1-type":      "Women",
+			"team1-level":     "National",
+		},
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(result.Message)
+	fmt.Println(store.Registrations(), store.Teams(result.SubscriptionID))
+	fmt.Println(spans.Names())
+	fmt.Println(logs.Messages()[0])
+	// Output:
+	// Thanks! Your registration number is 240001 with 1 teams.
+	// 1 [Ladies 1]
+	// [parse store handle]
+	// Handling form
+}
@@ -0,0 +1,146 @@
+// Package formtest provides fakes for the dependencies of a form.Handler, e.g.
+//
+//	clock := &formtest.Clock{T: time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)}
+//	store := formtest.NewMemoryStore()
+//	h, _ := form.NewHandlerWith(store, clock, &formtest.IDs{IDs: []string{"000001"}}, form.Config{})
+//
+// The handler logs through the standard logrus logger, set Config.Logger to the one of NewLogger
+// to keep its entries instead.
+package formtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// Clock is a form.Clock that only moves when told to
+type Clock struct {
+	mu sync.Mutex
+	T  time.Time
+}
+
+// Now returns the current fake time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.T
+}
+
+// Advance moves the fake time forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.T = c.T.Add(d)
+}
+
+// IDs is a form.IDGenerator handing out IDs in order, continuing with
+// sequential numbers once they run out
+type IDs struct {
+	mu   sync.Mutex
+	IDs  []string
+	next int
+}
+
+// NewID returns the next ID that does not occur in existing
+func (g *IDs) NewID(existing map[string]struct{}) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for {
+		var id string
+		if g.next < len(g.IDs) {
+			id = g.IDs[g.next]
+		} else {
+			id = fmt.Sprintf("%06d", g.next)
+		}
+		g.next++
+
+		if _, exists := existing[id]; !exists {
+			return id
+		}
+	}
+}
+
+// Spans is a form.SpanExporter keeping the spans in memory
+type Spans struct {
+	mu    sync.Mutex
+	spans []form.Span
+}
+
+// ExportSpan keeps span
+func (s *Spans) ExportSpan(span form.Span) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.spans = append(s.spans, span)
+}
+
+// Spans returns the finished spans in the order they finished
+func (s *Spans) Spans() []form.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]form.Span(nil), s.spans...)
+}
+
+// Names returns the names of the finished spans in the order they finished
+func (s *Spans) Names() []string {
+	var names []string
+	for _, span := range s.Spans() {
+		names = append(names, span.Name)
+	}
+
+	return names
+}
+
+// Logs keeps the entries logged through the logger of NewLogger in memory
+type Logs struct {
+	mu      sync.Mutex
+	entries []*log.Entry
+}
+
+// NewLogger creates a logger for form.Config.Logger that writes nothing and keeps its entries in Logs
+func NewLogger() (*log.Logger, *Logs) {
+	logs := &Logs{}
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.Level = log.DebugLevel
+	logger.Hooks.Add(logs)
+
+	return logger, logs
+}
+
+// Levels makes Logs receive the entries of every level
+func (l *Logs) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire keeps entry
+func (l *Logs) Fire(entry *log.Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// Messages returns the messages of the entries in the order they were logged
+func (l *Logs) Messages() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var messages []string
+	for _, entry := range l.entries {
+		messages = append(messages, entry.Message)
+	}
+
+	return messages
+}
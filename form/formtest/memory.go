@@ -0,0 +1,219 @@
+package formtest
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// DeadLetter is a submission that could not be handled
+type DeadLetter struct {
+	Message form.Message
+	Cause   error
+}
+
+// MemoryStore is a form.Store keeping registrations in memory
+type MemoryStore struct {
+	mu            sync.Mutex
+	registrations map[string]*memoryRegistration
+	DeadLetters   []DeadLetter
+}
+
+type memoryRegistration struct {
+	registration form.Registration
+	language     form.Language
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{registrations: make(map[string]*memoryRegistration)}
+}
+
+// Registrations returns the number of stored registrations
+func (m *MemoryStore) Registrations() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.registrations)
+}
+
+// Teams returns the names of the teams of a subscription in form order
+func (m *MemoryStore) Teams(subscriptionID string) (names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, ok := m.registrations[subscriptionID]; ok {
+		for _, team := range stored.registration.Teams {
+			names = append(names, team.Name)
+		}
+	}
+
+	return
+}
+
+func (m *MemoryStore) ExistingSubscriptionIDs(ctx context.Context) (map[string]struct{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subscriptionIDs := make(map[string]struct{}, len(m.registrations))
+	for subscriptionID := range m.registrations {
+		subscriptionIDs[subscriptionID] = struct{}{}
+	}
+
+	return subscriptionIDs, nil
+}
+
+func (m *MemoryStore) SubscriptionIDExists(ctx context.Context, subscriptionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, exists := m.registrations[subscriptionID]
+
+	return exists, nil
+}
+
+func (m *MemoryStore) SaveRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, maxRegistrations int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if maxRegistrations > 0 {
+		registrations := 0
+		for _, stored := range m.registrations {
+			if stored.registration.Year == registration.Year {
+				registrations++
+			}
+		}
+		if registrations >= maxRegistrations {
+			return 0, form.ErrSeasonFull
+		}
+	}
+
+	registration.Teams = append([]form.Team(nil), registration.Teams...)
+	m.registrations[subscriptionID] = &memoryRegistration{registration, language}
+
+	return len(registration.Teams), nil
+}
+
+func (m *MemoryStore) SaveDeadLetter(ctx context.Context, message form.Message, cause error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeadLetters = append(m.DeadLetters, DeadLetter{message, cause})
+
+	return nil
+}
+
+func (m *MemoryStore) Clubs(ctx context.Context, year int, limit int, offset int) ([]form.ClubSummary, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	teams := make(map[string]int)
+	for _, stored := range m.registrations {
+		if stored.registration.Year == year {
+			teams[stored.registration.Club] += len(stored.registration.Teams)
+		}
+	}
+
+	clubs := make([]form.ClubSummary, 0, len(teams))
+	for club, count := range teams {
+		clubs = append(clubs, form.ClubSummary{Club: club, Teams: count})
+	}
+	sort.Slice(clubs, func(i, j int) bool { return clubs[i].Club < clubs[j].Club })
+
+	total := len(clubs)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		clubs = clubs[offset : offset+limit]
+	} else {
+		clubs = clubs[offset:]
+	}
+
+	return clubs, total, nil
+}
+
+func (m *MemoryStore) AddTeam(ctx context.Context, subscriptionID string, team form.Team, maxTeams int, maxOfType int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.registrations[subscriptionID]
+	if !ok {
+		return 0, form.ErrSubscriptionNotFound
+	}
+
+	teams := stored.registration.Teams
+	if len(teams) >= maxTeams {
+		return 0, form.ErrTooManyTeams
+	}
+
+	ofType := 0
+	for _, t := range teams {
+		if t.Type == team.Type {
+			ofType++
+		}
+	}
+	if maxOfType > 0 && ofType >= maxOfType {
+		return 0, form.ErrTooManyOfType
+	}
+
+	team.Slot = 1
+	if len(teams) > 0 {
+		team.Slot = teams[len(teams)-1].Slot + 1
+	}
+	stored.registration.Teams = append(teams, team)
+
+	return len(stored.registration.Teams), nil
+}
+
+func (m *MemoryStore) ChangeSubscriptionID(ctx context.Context, oldID string, newID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.registrations[oldID]
+	if !ok {
+		return form.ErrSubscriptionNotFound
+	}
+
+	delete(m.registrations, oldID)
+	m.registrations[newID] = stored
+
+	return nil
+}
+
+func (m *MemoryStore) Registration(ctx context.Context, subscriptionID string) (form.Registration, form.Language, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.registrations[subscriptionID]
+	if !ok {
+		return form.Registration{}, "", form.ErrSubscriptionNotFound
+	}
+
+	return stored.registration, stored.language, nil
+}
+
+func (m *MemoryStore) DeleteRegistration(ctx context.Context, subscriptionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.registrations[subscriptionID]; !ok {
+		return form.ErrSubscriptionNotFound
+	}
+
+	delete(m.registrations, subscriptionID)
+
+	return nil
+}
+
+func (m *MemoryStore) TrialRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, schema string) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"inschrijfnummer": subscriptionID,
+		"jaar":            registration.Year,
+		"vereniging":      registration.Club,
+		"taal":            language.Code(),
+		"inschrijfdatum":  registration.SubmitTime,
+	}, nil
+}
@@ -1,11 +1,19 @@
 package form
 
 import (
-	"database/sql"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"expvar"
 	"fmt"
-	"math/rand"
+	"io"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -15,272 +23,1529 @@ import (
 type Message struct {
 	Title string            `json:"title"`
 	Data  map[string]string `json:"posted_data"`
+	// Source describes the client that sent the message, such as its User-Agent, it is set by the
+	// server and stored with StoreSource
+	Source string `json:"-"`
 }
 
-type form struct {
-	Club       string
-	Name       string
-	Surname    string
-	Email      string
-	Phone      string
+// Registration is a validated submission of the form, as it is stored
+type Registration struct {
+	Club      string
+	Name      string
+	Surname   string
+	Email     string
+	Phone     string
+	Notes     string
+	Reference string
+	// SecondContact is the optional second contact person, nil when the club has none
+	SecondContact *Contact
+	// ConsentVersion is the version of the privacy statement the contact consented to, if required
+	ConsentVersion string
+	ConsentTime    time.Time
+	// Extra holds the values of the configured ExtraFields
+	Extra      map[string]string
 	SubmitTime time.Time
-	Teams      []team
+	Year       int
+	// Season labels the season of the submit time, such as "2024-2025", empty without a SeasonStartMonth
+	Season string
+	// Source describes the client that sent the submission, empty without StoreSource
+	Source string
+	// Warnings are the localized problems that were accepted, such as a value stored as unknown
+	Warnings []string
+	Teams    []Team
 }
 
-type team struct {
+// Contact is an additional contact person of a club
+type Contact struct {
+	Name    string
+	Surname string
+	Email   string
+	Phone   string
+}
+
+// Team is a team of a registration, its type and level are Dutch
+type Team struct {
+	// Slot is the position of the team on the form, starting at 1
+	Slot  int
 	Name  string
 	Type  string
 	Level string
+	Poule string
+	// Availability lists the day codes the team prefers to play on
+	Availability string
+	// LevelCode is the numeric code of the level, 0 when the level has none
+	LevelCode int
+	// OriginalType and OriginalLevel hold the values as submitted on an English form, before translation
+	OriginalType  string
+	OriginalLevel string
+}
+
+// defaultUnknown is stored for values that cannot be translated
+const defaultUnknown = "Onbekend, check registration-handler"
+
+// enTypes maps the team types of the English form to their Dutch equivalents,
+// the Dutch form offers these values directly and is stored as is
+var enTypes = map[string]string{
+	"Men":         "Heren",
+	"Women":       "Dames",
+	"Mixed":       "Gemengd",
+	"Youth Boys":  "Jongens",
+	"Youth Girls": "Meisjes",
+}
+
+// enLevels maps the team levels of the English form to their Dutch equivalents
+var enLevels = map[string]string{
+	"National":        "Bond 2",
+	"Regional High":   "Regio 1",
+	"Regional Middle": "Regio 2",
+	"Regional Low":    "Regio 3-4",
+	"Recreational":    "Recreatief",
 }
 
-type language string
+type handledSubmission struct {
+	result Result
+	at     time.Time
+}
+
+// Result describes the outcome of a handled form submission
+type Result struct {
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	Teams          int    `json:"teams,omitempty"`
+	Message        string `json:"message,omitempty"`
+	// Queued is set in async mode, the submission is stored later and has no subscription ID yet
+	Queued bool `json:"queued,omitempty"`
+	// Details shows how each team was interpreted, after translation
+	Details []TeamResult `json:"details,omitempty"`
+	// Warnings lists the problems the club may want to review, they did not stop the registration
+	Warnings []string `json:"warnings,omitempty"`
+}
 
-const (
-	nl = language("NL")
-	en = language("EN")
+// TeamResult describes a team as it was stored
+type TeamResult struct {
+	Slot  int    `json:"slot"`
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Level string `json:"level"`
+	Poule string `json:"poule,omitempty"`
+	// Availability lists the day codes the team prefers to play on
+	Availability string `json:"availability,omitempty"`
+}
+
+var (
+	// ignoredMessages counts the messages ignored because of an unknown title
+	ignoredMessages = expvar.NewInt("ignored_messages")
+	// unknownValues counts the team values stored as unknown, which means the translation tables are outdated
+	unknownValues = expvar.NewInt("unknown_values")
 )
 
+// Config contains the settings of a Handler
+type Config struct {
+	// ExactTitles only accepts form titles that match exactly, by default case and surrounding
+	// whitespace are ignored
+	ExactTitles bool
+	// IgnoredAlertThreshold logs an error every time this many messages have been ignored, 0 disables it
+	IgnoredAlertThreshold int64
+	// Fields names the form fields, empty names default to those of DefaultFieldMapping
+	Fields FieldMapping
+	// SeasonYear is stored as the year of every registration, 0 derives it from the submit time
+	SeasonYear int
+	// SeasonStartMonth is the first month of a season, 0 stores no season label
+	SeasonStartMonth time.Month
+	// StrictSeason rejects submissions made in another year than SeasonYear
+	StrictSeason bool
+	// IDStrategy selects how subscription IDs are generated, either random (default) or sequential
+	IDStrategy string
+	// IDWidth is the number of digits of random subscription IDs, defaults to 6
+	IDWidth int
+	// IDNoLeadingZero generates random subscription IDs that do not start with a zero, so external
+	// systems that store them as numbers do not strip it
+	IDNoLeadingZero bool
+	// IDPrefix is put in front of random subscription IDs, it may not start with a zero and the
+	// complete ID must fit in 10 characters
+	IDPrefix string
+	// IDLoading is eager (default) to load all existing subscription IDs on startup, or lazy to
+	// look up every new ID in the store instead
+	IDLoading string
+	// Clubs are the canonical club names that misspelled clubs are matched against, empty disables matching
+	Clubs []string
+	// ClubMaxDistance is the number of typos tolerated when matching clubs, defaults to 2
+	ClubMaxDistance int
+	// DuplicateWindow is how long an identical submission returns the earlier result, defaults to 24 hours
+	DuplicateWindow time.Duration
+	// DuplicateMode is idempotent (default) to return the earlier result for a resubmission, or
+	// conflict to return it with ErrDuplicate
+	DuplicateMode string
+	// MinSubmitInterval is the minimum time between accepted submissions of the same club and email,
+	// a different submission within it is rejected with TooSoonError, 0 disables the check
+	MinSubmitInterval time.Duration
+	// AllowedEmailDomains restricts the contact email to these domains, empty allows any domain
+	AllowedEmailDomains []string
+	// MaxTeams is the number of team slots on the form and the maximum number of teams per subscription, defaults to 5
+	MaxTeams int
+	// ContiguousTeams rejects submissions that skip a team slot, such as a third team without a second
+	ContiguousTeams bool
+	// SortTeams stores the teams ordered by type and name instead of in form order, so the same
+	// teams in other slots are the same submission
+	SortTeams bool
+	// StrictTeamSlots rejects submissions with teams beyond MaxTeams instead of dropping them with a warning
+	StrictTeamSlots bool
+	// HoneypotField is a hidden form field that only bots fill in, empty disables the check
+	HoneypotField string
+	// Poules lists the valid poules per (Dutch) team type, types without poules accept any poule
+	Poules map[string][]string
+	// LevelCodes maps the (Dutch) levels to numeric codes for downstream systems
+	LevelCodes map[string]int
+	// StrictLevelCodes rejects levels without a code instead of storing them without one
+	StrictLevelCodes bool
+	// Days are the valid day codes of the team availability, empty accepts anything
+	Days []string
+	// StrictAvailability rejects unknown day codes instead of dropping them with a warning
+	StrictAvailability bool
+	// RegionalLevels lists the regional levels played per (Dutch) team type, other regional levels
+	// are rejected for that type, types without regional levels accept any level
+	RegionalLevels map[string][]string
+	// StrictPoules rejects unknown poules instead of storing them as unknown
+	StrictPoules bool
+	// TypePatterns and LevelPatterns translate the English types and levels without an exact
+	// translation, the first matching pattern is used
+	TypePatterns  []ValuePattern
+	LevelPatterns []ValuePattern
+	// Unknown is stored for values that cannot be translated, defaults to "Onbekend, check registration-handler"
+	Unknown string
+	// DutchValidation checks the type and level of the Dutch form: off (default), warn or strict
+	DutchValidation string
+	// QueueSize enables async mode, where up to this many submissions wait to be stored, 0 stores synchronously
+	QueueSize int
+	// QueueRetries is how often a queued submission is retried after a transient failure before it
+	// becomes a dead letter, defaults to 2, -1 disables retries
+	QueueRetries int
+	// QueueBackoff is the wait before the first retry of a queued submission, every next retry waits
+	// one backoff longer, defaults to a second
+	QueueBackoff time.Duration
+	// Location is the time zone of the submit time and the season year, defaults to UTC
+	Location *time.Location
+	// RequiredTypes are the (Dutch) team types every subscription must contain at least one team of
+	RequiredTypes []string
+	// MaxTeamsPerType caps the number of teams of a (Dutch) team type per subscription, types
+	// that are not listed are only limited by MaxTeams
+	MaxTeamsPerType map[string]int
+	// UniqueTeamNames rejects a submission with the same team name twice, ignoring case and spacing,
+	// instead of only logging it
+	UniqueTeamNames bool
+	// StoreRetries is how often storing is retried after a transient database error, defaults to 2, -1 disables retries
+	StoreRetries int
+	// MaxRegistrations closes registration once a season has this many registrations, 0 is unlimited
+	MaxRegistrations int
+	// Blocklist contains email addresses and club names that may not register
+	Blocklist []string
+	// ConsentVersion is the current version of the privacy statement, setting it requires consent
+	ConsentVersion string
+	// PhoneRegion interprets national phone numbers, such as 06 numbers for NL, defaults to NL
+	PhoneRegion string
+	// PhoneRegionFromLanguage uses the region of the form language instead, if it has one
+	PhoneRegionFromLanguage bool
+	// ExtraFields are additional fields every submission must fill in, such as a license number
+	ExtraFields []ExtraField
+	// HTTPClient makes the outbound calls, defaults to NewHTTPClient with a 10 second timeout
+	HTTPClient *http.Client
+	// SpanExporter receives the spans of handling a message, which defaults to logging them at debug
+	// level
+	SpanExporter SpanExporter
+	// Logger receives the log entries of the handler, defaults to the standard logrus logger. The
+	// Store and the translation helpers always log through the standard logger.
+	Logger *log.Logger
+	// StoreSource stores the Source of every message with the registration, to trace parsing
+	// problems back to a version of the form plugin
+	StoreSource bool
+	// AuditSize is the number of recent submissions kept for the audit log, defaults to 100
+	AuditSize int
+	// CallbackURL receives the subscription ID of every stored registration, empty disables the callback
+	CallbackURL string
+}
+
+// ExtraField is a required form field that is stored with the subscription
+type ExtraField struct {
+	Name string
+	// Pattern must match the value, nil accepts any non empty value
+	Pattern *regexp.Regexp
+}
+
+// ValuePattern translates the English values matching Pattern to the Dutch Value, for form options
+// with extra text such as "Men (recreational)"
+type ValuePattern struct {
+	Pattern *regexp.Regexp
+	Value   string
+}
+
 // Handler handles form submissions
 type Handler interface {
-	Handle(message Message) error
+	Handle(ctx context.Context, message Message) (Result, error)
+	// AddTeam adds a team to an existing subscription and returns its number of teams
+	AddTeam(ctx context.Context, subscriptionID string, team TeamRequest) (Result, error)
+	// RegenerateID assigns a new subscription ID to an existing subscription
+	RegenerateID(ctx context.Context, subscriptionID string) (Result, error)
+	// Confirmation writes a printable HTML confirmation of a subscription in its language
+	Confirmation(ctx context.Context, subscriptionID string, w io.Writer) error
+	// Delete removes a subscription and its teams, releasing its subscription ID
+	Delete(ctx context.Context, subscriptionID string) error
+	// Trial parses the message and stores it in the tables of schema without keeping it, returning
+	// the registration as it was stored
+	Trial(ctx context.Context, message Message, schema string) (map[string]interface{}, error)
+	// Recent returns up to n of the most recently handled submissions, the newest first, a negative
+	// n returns all that are kept
+	Recent(n int) []AuditEntry
+	// Close waits until all queued submissions are stored and their callbacks sent, no messages may be handled afterwards
+	Close()
 }
 
 type handler struct {
 	subscriptionIDs map[string]struct{}
-	db              *sql.DB
-	rng             *rand.Rand
+	store           Store
+	ids             IDGenerator
+	decoyIDs        IDGenerator
+	idsMu           sync.Mutex
+	clock           Clock
+	clubLocks       *keyedLock
+	config          Config
+	// handled remembers the result per submission so retried deliveries and double submits are not stored twice
+	handled   map[string]handledSubmission
+	handledMu sync.Mutex
+	// accepted remembers when each club and email last submitted, for the MinSubmitInterval
+	accepted   map[string]time.Time
+	acceptedMu sync.Mutex
+	// audit keeps the outcomes of the most recent submissions
+	audit *auditLog
+	// queue is only set in async mode, its jobs are stored by a worker
+	queue      chan job
+	workerDone sync.WaitGroup
+	// callbacks tracks the callbacks that are still being sent
+	callbacks sync.WaitGroup
 }
 
 // NewHandler creates a new Handler
-func NewHandler(db *sql.DB) (h Handler, err error) {
-	subscriptionIDs := make(map[string]struct{})
-	var rows *sql.Rows
-	if rows, err = db.Query("SELECT inschrijfnummer FROM inschrijving"); err != nil {
+func NewHandler(store Store, config Config) (h Handler, err error) {
+	var ids IDGenerator
+	if ids, err = newIDGenerator(config); err != nil {
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var subscriptionID string
-		if err = rows.Scan(&subscriptionID); err != nil {
+
+	return NewHandlerWith(store, systemClock{}, ids, config)
+}
+
+// NewHandlerWith creates a new Handler using the given clock and ID generator instead of
+// the system clock and the ID strategy of config, which makes the handler deterministic in tests
+func NewHandlerWith(store Store, clock Clock, ids IDGenerator, config Config) (h Handler, err error) {
+	if err = checkLanguages(); err != nil {
+		return
+	}
+
+	subscriptionIDs := make(map[string]struct{})
+	switch config.IDLoading {
+	case "", "eager":
+		if subscriptionIDs, err = store.ExistingSubscriptionIDs(context.Background()); err != nil {
 			return
 		}
-		subscriptionIDs[subscriptionID] = struct{}{}
+	case "lazy":
+	default:
+		err = fmt.Errorf("Unknown subscription ID loading: %s", config.IDLoading)
+		return
+	}
+
+	config.Fields = config.Fields.withDefaults()
+
+	if config.Logger == nil {
+		config.Logger = log.StandardLogger()
+	}
+
+	if config.Location == nil {
+		config.Location = time.UTC
+	}
+
+	if config.QueueRetries == 0 {
+		config.QueueRetries = 2
+	}
+
+	if config.QueueBackoff == 0 {
+		config.QueueBackoff = time.Second
+	}
+
+	if config.StoreRetries == 0 {
+		config.StoreRetries = 2
+	}
+
+	if config.Unknown == "" {
+		config.Unknown = defaultUnknown
 	}
 
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	if config.MaxTeams == 0 {
+		config.MaxTeams = 5
+	}
 
-	h = &handler{
-		subscriptionIDs,
-		db,
-		rng,
+	if config.DuplicateMode != "" && config.DuplicateMode != "idempotent" && config.DuplicateMode != "conflict" {
+		err = fmt.Errorf("Unknown duplicate mode: %s", config.DuplicateMode)
+		return
+	}
+
+	for level := range config.LevelCodes {
+		if !knownLevel(level) {
+			config.Logger.WithField("level", level).Warn("Level code configured for a level the form does not have")
+		}
+	}
+
+	if config.SeasonStartMonth < 0 || config.SeasonStartMonth > time.December {
+		err = fmt.Errorf("Season start month must be between 1 and 12, got %d", config.SeasonStartMonth)
+		return
+	}
+
+	if config.PhoneRegion == "" {
+		config.PhoneRegion = "NL"
+	}
+	if _, ok := callingCodes[config.PhoneRegion]; !ok {
+		err = fmt.Errorf("Unknown phone region: %s", config.PhoneRegion)
+		return
 	}
 
+	if config.HTTPClient == nil {
+		config.HTTPClient = NewHTTPClient(10 * time.Second)
+	}
+
+	if config.SpanExporter == nil {
+		config.SpanExporter = logExporter{}
+	}
+
+	if config.DuplicateWindow == 0 {
+		config.DuplicateWindow = 24 * time.Hour
+	}
+
+	if config.AuditSize <= 0 {
+		config.AuditSize = 100
+	}
+
+	if config.ClubMaxDistance == 0 {
+		config.ClubMaxDistance = 2
+	}
+
+	handler := &handler{
+		subscriptionIDs: subscriptionIDs,
+		store:           store,
+		ids:             ids,
+		decoyIDs:        newDecoyIDs(ids),
+		clock:           clock,
+		clubLocks:       newKeyedLock(),
+		config:          config,
+		handled:         make(map[string]handledSubmission),
+		accepted:        make(map[string]time.Time),
+		audit:           newAuditLog(config.AuditSize),
+	}
+
+	if config.QueueSize > 0 {
+		handler.queue = make(chan job, config.QueueSize)
+		handler.workerDone.Add(1)
+		go handler.work()
+	}
+
+	h = handler
+
 	return
 }
 
-func (h *handler) Handle(message Message) (err error) {
-	var lang language
-
-	switch message.Title {
-	case "Inschrijven teams":
-		lang = nl
-		log.Info("Handling Dutch form")
-	case "Sign up teams":
-		lang = en
-		log.Info("Handling English form")
+func newIDGenerator(config Config) (ids IDGenerator, err error) {
+	switch config.IDStrategy {
+	case "", "random":
+		width := config.IDWidth
+		if width == 0 {
+			width = 6
+		}
+		if width < 1 || width > 9 {
+			err = fmt.Errorf("Subscription ID width must be between 1 and 9, got %d", width)
+			return
+		}
+		switch {
+		case strings.HasPrefix(config.IDPrefix, "0"):
+			err = fmt.Errorf("Subscription ID prefix may not start with a zero, got %s", config.IDPrefix)
+		case len(config.IDPrefix)+width > 10:
+			err = fmt.Errorf("Subscription ID prefix %s is too long for IDs of %d digits", config.IDPrefix, width)
+		case config.IDPrefix != "":
+			ids = NewPrefixedRandomIDs(config.IDPrefix, width)
+		case config.IDNoLeadingZero:
+			ids = NewNumericRandomIDs(width)
+		default:
+			ids = NewRandomIDs(width)
+		}
+	case "sequential":
+		year := config.SeasonYear
+		if year == 0 {
+			year = time.Now().Year()
+		}
+		ids = NewSequentialIDs(year)
 	default:
-		log.WithField("title", message.Title).Info("Ignoring message")
+		err = fmt.Errorf("Unknown subscription ID strategy: %s", config.IDStrategy)
+	}
+
+	return
+}
+
+func (h *handler) Handle(ctx context.Context, message Message) (result Result, err error) {
+	var outcome string
+	defer func() {
+		h.record(message, outcome, result, err)
+	}()
+
+	lang, ok := languageOf(message.Title, h.config.ExactTitles)
+	if !ok {
+		outcome = outcomeIgnored
+		h.ignore(message)
 		return
 	}
 
-	var form form
-	if form, err = parseData(message.Data, lang); err != nil {
-		log.WithFields(log.Fields(map[string]interface{}{
+	h.config.Logger.WithField("language", lang.Code()).Info("Handling form")
+
+	field := h.config.HoneypotField
+	spam := field != "" && message.Data[field] != ""
+
+	if len(message.Data) == 0 {
+		err = ValidationErrors{localize(lang, msgEmptySubmission)}
+		h.config.Logger.WithField("title", message.Title).Error("Received message without data")
+		return
+	}
+
+	ctx, span := h.startSpan(ctx, "handle")
+	span.set("language", lang.Code())
+	defer func() {
+		span.set("teams", result.Teams)
+		span.end(err)
+	}()
+
+	var form Registration
+	_, parseSpan := h.startSpan(ctx, "parse")
+	form, err = parseData(message.Data, lang, h.config, h.clock.Now())
+	parseSpan.set("teams", len(form.Teams))
+	parseSpan.end(err)
+	if err != nil && spam {
+		// answered like any invalid submission, but spam is not worth a dead letter
+		h.config.Logger.WithField("title", message.Title).Warn("Dropping invalid spam submission with filled honeypot")
+		outcome = outcomeIgnored
+		return
+	}
+	if err != nil {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
 			"error": err,
 			"data":  message.Data,
 		})).Error("Failed to parse data")
+
+		if deadLetterErr := h.store.SaveDeadLetter(ctx, message, err); deadLetterErr != nil {
+			h.config.Logger.WithField("error", deadLetterErr).Error("Failed to store dead letter")
+		}
 		return
 	}
 
-	if err = h.storeForm(form, lang); err != nil {
-		log.WithField("error", err).Error("Failed to store form")
+	if spam {
+		// pretend success so bots do not learn that they were caught
+		h.config.Logger.WithField("title", message.Title).Warn("Dropping spam submission with filled honeypot")
+		outcome = outcomeIgnored
+		return h.decoy(form, lang), nil
 	}
 
-	return
-}
+	if h.config.StoreSource {
+		form.Source = message.Source
+	}
+
+	// serialize submissions of the same club so a double submit cannot race
+	unlock := h.clubLocks.Lock(clubKey(form))
+	defer unlock()
 
-func (h *handler) storeForm(form form, language language) (err error) {
-	var tx *sql.Tx
-	if tx, err = h.db.Begin(); err != nil {
-		log.WithField("error", err).Error("Failed to start transaction")
+	key := h.submissionKey(message, form)
+	if previous, ok := h.previousResult(key); ok {
+		h.config.Logger.WithField("subscriptionID", previous.SubscriptionID).Info("Submission already handled")
+		outcome = outcomeDuplicate
+		if h.config.DuplicateMode == "conflict" {
+			return previous, ErrDuplicate
+		}
+		return previous, nil
+	}
+
+	if wait := h.tooSoon(clubKey(form)); wait > 0 {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"club": form.Club,
+			"wait": wait,
+		})).Warn("Rejecting submission within the minimum submit interval")
+		err = TooSoonError{localize(lang, msgTooSoon), wait}
 		return
 	}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback()
+	if h.queue != nil {
+		if err = h.enqueue(job{message, form, lang, key}); err != nil {
+			h.config.Logger.WithField("error", err).Error("Failed to queue form")
+			return
 		}
-	}()
 
-	subscriptionID := h.createSubscriptionID()
+		h.accept(clubKey(form))
+		result.Queued = true
+		result.Warnings = form.Warnings
+		return
+	}
 
-	// this is not how it used to work but since the sign-up season typically runs from
-	// April to August, this should be safe enough
-	year := time.Now().Year()
+	var subscriptionID string
+	if result, err = h.save(ctx, form, lang, key, &subscriptionID); err == nil {
+		h.accept(clubKey(form))
+	}
 
-	query := `
-		INSERT INTO inschrijving (
-			inschrijfnummer, jaar, voornaam, achternaam, email, telefoon, vereniging, taal, inschrijfdatum
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id
-	`
+	return
+}
 
-	log.WithFields(log.Fields(map[string]interface{}{
-		"query":          query,
-		"subscriptionID": subscriptionID,
-		"year":           year,
-		"name":           form.Name,
-		"surname":        form.Surname,
-		"email":          form.Email,
-		"phone":          form.Phone,
-		"club":           form.Club,
-		"language":       string(language),
-		"submitTime":     form.SubmitTime,
-	})).Info("Insert inschrijving")
-
-	if _, err = tx.Exec(query,
-		trim(subscriptionID, 6),
-		year,
-		trim(form.Name, 20),
-		trim(form.Surname, 30),
-		trim(form.Email, 50),
-		trim(form.Phone, 20),
-		trim(form.Club, 50),
-		trim(string(language), 2),
-		form.SubmitTime.Format("2006-01-02 15:04:05"),
-	); err != nil {
-		log.WithField("error", err).Error("Failed to create subscription")
+// tooSoon returns how long the club still has to wait before it may submit again, 0 when it may
+func (h *handler) tooSoon(club string) time.Duration {
+	if h.config.MinSubmitInterval <= 0 {
+		return 0
+	}
+
+	h.acceptedMu.Lock()
+	defer h.acceptedMu.Unlock()
+
+	now := h.clock.Now()
+	// forget submissions outside the interval, which also keeps the map small
+	for k, at := range h.accepted {
+		if now.Sub(at) >= h.config.MinSubmitInterval {
+			delete(h.accepted, k)
+		}
+	}
+
+	at, ok := h.accepted[club]
+	if !ok {
+		return 0
+	}
+
+	return h.config.MinSubmitInterval - now.Sub(at)
+}
+
+// accept records an accepted submission of the club for the MinSubmitInterval
+func (h *handler) accept(club string) {
+	if h.config.MinSubmitInterval <= 0 {
 		return
 	}
 
-	placeholders := make([]string, 0, len(form.Teams))
-	values := make([]interface{}, 0, 3*len(form.Teams))
+	h.acceptedMu.Lock()
+	h.accepted[club] = h.clock.Now()
+	h.acceptedMu.Unlock()
+}
 
-	for i, team := range form.Teams {
-		placeholders = append(
-			placeholders,
-			fmt.Sprintf("(currval('inschrijving_id_seq'), $%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3),
-		)
-		values = append(
-			values,
-			trim(team.Name, 40),
-			trim(team.Type, 40),
-			trim(team.Level, 40),
-		)
+// decoy returns a result shaped like that of a stored form, without storing it or reserving its
+// subscription ID
+func (h *handler) decoy(form Registration, lang Language) Result {
+	if h.queue != nil {
+		return Result{Queued: true, Warnings: form.Warnings}
 	}
 
-	query = `
-		INSERT INTO team (inschrijvingsid, teamnaam, "type", niveau)
-		VALUES
-	` + strings.Join(placeholders, ",")
+	h.idsMu.Lock()
+	subscriptionID := h.decoyIDs.NewID(h.subscriptionIDs)
+	h.idsMu.Unlock()
 
-	log.WithFields(log.Fields(map[string]interface{}{
-		"query":  query,
-		"values": values,
-	})).Info("Inserting teams")
+	return newResult(form, lang, subscriptionID, len(form.Teams))
+}
 
-	if _, err = tx.Exec(query, values...); err != nil {
-		log.WithField("error", err).Error("Failed to create teams")
+// save stores the form and remembers the result for the duplicate window. The subscription ID is
+// generated when empty and kept after a failure, so the next attempt reuses it.
+func (h *handler) save(ctx context.Context, form Registration, lang Language, key string, subscriptionID *string) (result Result, err error) {
+	var teams int
+	if teams, err = h.storeForm(ctx, form, lang, subscriptionID); err != nil {
+		h.config.Logger.WithField("error", err).Error("Failed to store form")
+		if isTransient(err) {
+			err = TransientError{err}
+		}
 		return
 	}
 
-	if err = tx.Commit(); err != nil {
-		log.WithField("error", err).Error("Failed to commit transaction")
+	result = newResult(form, lang, *subscriptionID, teams)
+
+	h.handledMu.Lock()
+	h.handled[key] = handledSubmission{result, h.clock.Now()}
+	h.handledMu.Unlock()
+
+	if h.config.CallbackURL != "" {
+		h.callback(result, form.Reference)
 	}
 
 	return
 }
 
-// Note: not thread-safe but should be good enough in practice
-func (h *handler) createSubscriptionID() string {
+// forgetResults drops the remembered results of a deleted subscription, so a resubmission within
+// the duplicate window is stored again
+func (h *handler) forgetResults(subscriptionID string) {
+	h.handledMu.Lock()
+	defer h.handledMu.Unlock()
+
+	for key, submission := range h.handled {
+		if submission.result.SubscriptionID == subscriptionID {
+			delete(h.handled, key)
+		}
+	}
+}
+
+// renameResults points the remembered results of a subscription to its regenerated ID, so a
+// resubmission within the duplicate window returns the ID that exists
+func (h *handler) renameResults(oldID string, newID string) {
+	h.handledMu.Lock()
+	defer h.handledMu.Unlock()
+
+	for key, submission := range h.handled {
+		if submission.result.SubscriptionID == oldID {
+			submission.result.SubscriptionID = newID
+			submission.result.Message = strings.Replace(submission.result.Message, oldID, newID, -1)
+			h.handled[key] = submission
+		}
+	}
+}
+
+// newResult describes the form stored under subscriptionID with teams teams
+func newResult(form Registration, lang Language, subscriptionID string, teams int) Result {
+	result := Result{
+		SubscriptionID: subscriptionID,
+		Teams:          teams,
+		Message:        localize(lang, msgConfirmation, subscriptionID, teams),
+		Warnings:       form.Warnings,
+	}
+
+	for _, team := range form.Teams {
+		result.Details = append(result.Details, TeamResult{
+			Slot:         team.Slot,
+			Name:         team.Name,
+			Type:         team.Type,
+			Level:        team.Level,
+			Poule:        team.Poule,
+			Availability: team.Availability,
+		})
+	}
+
+	return result
+}
+
+// previousResult returns the result of an identical submission handled within the duplicate window
+func (h *handler) previousResult(key string) (result Result, ok bool) {
+	h.handledMu.Lock()
+	defer h.handledMu.Unlock()
+
+	// forget submissions outside the window, which also keeps the map small
+	for k, submission := range h.handled {
+		if h.clock.Now().Sub(submission.at) > h.config.DuplicateWindow {
+			delete(h.handled, k)
+		}
+	}
+
+	var submission handledSubmission
+	submission, ok = h.handled[key]
+	return submission.result, ok
+}
+
+// idempotencyKey identifies a submission by its title and data, regardless of the order of the
+// fields and of the case and surrounding whitespace of the values
+func idempotencyKey(message Message) string {
+	keys := make([]string, 0, len(message.Data))
+	for key := range message.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%q\n", message.Title)
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%q=%q\n", key, teamNameKey(message.Data[key]))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// submissionKey is the idempotency key of a submission, with sorted teams the team fields are
+// replaced by the sorted teams so their slots do not matter
+func (h *handler) submissionKey(message Message, form Registration) string {
+	if !h.config.SortTeams {
+		return idempotencyKey(message)
+	}
+
+	fields := h.config.Fields
+	teamFields := []string{fields.TeamName, fields.TeamType, fields.TeamLevel, fields.TeamPoule, fields.TeamAvailability}
+
+	canonical := Message{Title: message.Title, Data: make(map[string]string, len(message.Data))}
+	for key, value := range message.Data {
+		canonical.Data[key] = value
+	}
+	for i := 1; i <= h.config.MaxTeams; i++ {
+		for _, field := range teamFields {
+			delete(canonical.Data, fmt.Sprintf(field, i))
+		}
+	}
+	for _, team := range form.Teams {
+		canonical.Data[fmt.Sprintf(fields.TeamName, team.Slot)] = team.Name
+		canonical.Data[fmt.Sprintf(fields.TeamType, team.Slot)] = team.Type
+		canonical.Data[fmt.Sprintf(fields.TeamLevel, team.Slot)] = team.Level
+		canonical.Data[fmt.Sprintf(fields.TeamPoule, team.Slot)] = team.Poule
+		canonical.Data[fmt.Sprintf(fields.TeamAvailability, team.Slot)] = team.Availability
+	}
+
+	return idempotencyKey(canonical)
+}
+
+func (h *handler) ignore(message Message) {
+	ignoredMessages.Add(1)
+	ignored := ignoredMessages.Value()
+
+	h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+		"title":   message.Title,
+		"ignored": ignored,
+	})).Warn("Ignoring message with unknown title")
+
+	if threshold := h.config.IgnoredAlertThreshold; threshold > 0 && ignored%threshold == 0 {
+		h.config.Logger.WithField("ignored", ignored).Error("Many messages ignored, has the form been renamed?")
+	}
+}
+
+// storeForm stores the form under subscriptionID, generating one when it is empty. A retry after a
+// transient failure keeps the ID, so the unique index on inschrijfnummer catches a commit that
+// succeeded although it was reported as failed.
+func (h *handler) storeForm(ctx context.Context, form Registration, language Language, subscriptionID *string) (teams int, err error) {
+	// an ID from an earlier attempt may already have been stored
+	ambiguous := *subscriptionID != ""
+	var taken, retries int
+	for {
+		if *subscriptionID == "" {
+			if *subscriptionID, err = h.createSubscriptionID(ctx); err != nil {
+				return
+			}
+		}
+
+		_, span := h.startSpan(ctx, "store")
+		span.set("language", language.Code())
+		span.set("subscriptionID", *subscriptionID)
+		teams, err = h.store.SaveRegistration(ctx, form, *subscriptionID, language, h.config.MaxRegistrations)
+		span.set("teams", teams)
+		span.end(err)
+
+		switch {
+		case err == nil:
+			return
+		case isUniqueViolation(err) && ambiguous && h.storedEarlier(ctx, form, *subscriptionID, &teams):
+			h.config.Logger.WithField("subscriptionID", *subscriptionID).Warn("Earlier attempt was stored after all")
+			return teams, nil
+		case isUniqueViolation(err) && taken < 2:
+			// the database enforces unique IDs, another instance may have taken the ID in the meantime
+			taken++
+			h.config.Logger.WithField("subscriptionID", *subscriptionID).Warn("Subscription ID already taken, retrying")
+			*subscriptionID, ambiguous = "", false
+		case isTransient(err) && retries < h.config.StoreRetries:
+			ambiguous = true
+			backoff := time.Duration(100<<uint(retries)) * time.Millisecond
+			retries++
+			h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+				"error":   err,
+				"retry":   retries,
+				"backoff": backoff,
+			})).Warn("Transient failure storing form, retrying")
+
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// storedEarlier reports whether the registration under subscriptionID is the form itself, stored by
+// an attempt that failed after its commit, and sets teams to its number of teams
+func (h *handler) storedEarlier(ctx context.Context, form Registration, subscriptionID string, teams *int) bool {
+	stored, _, err := h.store.Registration(ctx, subscriptionID)
+	if err != nil {
+		h.config.Logger.WithField("error", err).Error("Failed to look up the earlier attempt")
+		return false
+	}
+
+	if stored.Club != trim(form.Club, 50) || !strings.EqualFold(stored.Email, trim(form.Email, 50)) {
+		return false
+	}
+
+	*teams = len(stored.Teams)
+	return true
+}
+
+func (h *handler) createSubscriptionID(ctx context.Context) (string, error) {
 	for {
-		newID := fmt.Sprintf("%06d", h.rng.Int()%1000000)
-		if _, exists := h.subscriptionIDs[newID]; !exists {
-			h.subscriptionIDs[newID] = struct{}{}
-			return newID
+		h.idsMu.Lock()
+		newID := h.ids.NewID(h.subscriptionIDs)
+		h.subscriptionIDs[newID] = struct{}{}
+		h.idsMu.Unlock()
+
+		if h.config.IDLoading != "lazy" {
+			return newID, nil
+		}
+
+		// the ID stays reserved when it exists, so the next attempt generates another one
+		exists, err := h.store.SubscriptionIDExists(ctx, newID)
+		if err != nil {
+			h.releaseSubscriptionID(newID)
+			return "", err
+		}
+		if !exists {
+			return newID, nil
 		}
 	}
 }
 
-func parseData(data map[string]string, language language) (parsed form, err error) {
+func (h *handler) Trial(ctx context.Context, message Message, schema string) (row map[string]interface{}, err error) {
+	lang, ok := languageOf(message.Title, h.config.ExactTitles)
+	if !ok {
+		err = ValidationErrors{fmt.Sprintf("Unknown form title: %s", message.Title)}
+		return
+	}
+
+	var form Registration
+	if form, err = parseData(message.Data, lang, h.config, h.clock.Now()); err != nil {
+		return
+	}
+
+	// the ID is not reserved since the registration is rolled back
+	h.idsMu.Lock()
+	subscriptionID := h.ids.NewID(h.subscriptionIDs)
+	h.idsMu.Unlock()
+
+	if row, err = h.store.TrialRegistration(ctx, form, subscriptionID, lang, schema); err != nil {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"error":  err,
+			"schema": schema,
+		})).Error("Failed to store trial registration")
+	}
+
+	return
+}
+
+func (h *handler) RegenerateID(ctx context.Context, subscriptionID string) (result Result, err error) {
+	var newID string
+	if newID, err = h.createSubscriptionID(ctx); err != nil {
+		return
+	}
+
+	if err = h.store.ChangeSubscriptionID(ctx, subscriptionID, newID); err != nil {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"error":          err,
+			"subscriptionID": subscriptionID,
+		})).Error("Failed to regenerate subscription ID")
+		h.releaseSubscriptionID(newID)
+		return
+	}
+
+	h.releaseSubscriptionID(subscriptionID)
+	h.renameResults(subscriptionID, newID)
+
+	h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+		"old": subscriptionID,
+		"new": newID,
+	})).Info("Regenerated subscription ID")
+
+	result.SubscriptionID = newID
+
+	return
+}
+
+func (h *handler) Delete(ctx context.Context, subscriptionID string) (err error) {
+	if err = h.store.DeleteRegistration(ctx, subscriptionID); err != nil {
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"error":          err,
+			"subscriptionID": subscriptionID,
+		})).Error("Failed to delete subscription")
+		return
+	}
+
+	h.releaseSubscriptionID(subscriptionID)
+	h.forgetResults(subscriptionID)
+
+	h.config.Logger.WithField("subscriptionID", subscriptionID).Warn("Deleted subscription")
+
+	return
+}
+
+func (h *handler) releaseSubscriptionID(subscriptionID string) {
+	h.idsMu.Lock()
+	defer h.idsMu.Unlock()
+
+	delete(h.subscriptionIDs, subscriptionID)
+}
+
+func parseData(data map[string]string, language Language, config Config, now time.Time) (parsed Registration, err error) {
+	var problems ValidationErrors
+
+	// some fields are submitted as a single space when left empty
+	normalized := make(map[string]string, len(data))
+	for key, value := range data {
+		normalized[key] = strings.TrimSpace(value)
+	}
+	data = normalized
+
 	readEntry := func(key string) (value string) {
-		if err == nil {
-			if value = data[key]; value == "" {
-				err = fmt.Errorf("Missing required value: %s", key)
-			}
+		if value = data[key]; value == "" {
+			problems = append(problems, localize(language, msgMissingValue, key))
 		}
 		return
 	}
 
-	parsed.Club = readEntry("contact-club")
-	parsed.Name = readEntry("contact-name")
-	parsed.Surname = readEntry("contact-surname")
-	parsed.Email = readEntry("contact-email")
-	parsed.Phone = readEntry("contact-phone")
-	parsed.SubmitTime = time.Now()
+	fields := config.Fields
+	parsed.Club = canonicalClub(readEntry(fields.Club), config.Clubs, config.ClubMaxDistance)
+	if fullName := data[fields.FullName]; fullName != "" && data[fields.Name] == "" && data[fields.Surname] == "" {
+		parsed.Name, parsed.Surname = splitName(fullName)
+		if parsed.Surname == "" {
+			config.Logger.WithField("name", fullName).Warn("Full name has no surname")
+		}
+	} else {
+		parsed.Name = readEntry(fields.Name)
+		parsed.Surname = readEntry(fields.Surname)
+	}
+	parsed.Email = readEntry(fields.Email)
+	parsed.Phone = readEntry(fields.Phone)
+
+	region := config.PhoneRegion
+	if r := languages[language].region; config.PhoneRegionFromLanguage && r != "" {
+		region = r
+	}
+	normalize := func(phone *string) {
+		if *phone == "" {
+			return
+		}
+		var ok bool
+		if *phone, ok = normalizePhone(*phone, region); !ok {
+			parsed.Warnings = append(parsed.Warnings, localize(language, msgUnusualPhone, *phone))
+		}
+	}
+	normalize(&parsed.Phone)
+
+	// the second contact is optional, but once any of its fields is filled in all of them are required
+	secondFields := []string{fields.SecondName, fields.SecondSurname, fields.SecondEmail, fields.SecondPhone}
+	for _, field := range secondFields {
+		if data[field] == "" {
+			continue
+		}
+
+		parsed.SecondContact = &Contact{
+			Name:    readEntry(fields.SecondName),
+			Surname: readEntry(fields.SecondSurname),
+			Email:   readEntry(fields.SecondEmail),
+			Phone:   readEntry(fields.SecondPhone),
+		}
+		normalize(&parsed.SecondContact.Phone)
+		break
+	}
+	parsed.Notes = data[fields.Notes]
+	parsed.Reference = data[fields.Reference]
+	parsed.SubmitTime = now.In(config.Location)
+
+	for _, field := range config.ExtraFields {
+		value := readEntry(field.Name)
+		if value == "" {
+			continue
+		}
+		if field.Pattern != nil && !field.Pattern.MatchString(value) {
+			problems = append(problems, localize(language, msgInvalidField, value, field.Name))
+			continue
+		}
+		if parsed.Extra == nil {
+			parsed.Extra = make(map[string]string, len(config.ExtraFields))
+		}
+		parsed.Extra[field.Name] = value
+	}
+
+	if config.ConsentVersion != "" {
+		if consented(data[fields.Consent]) {
+			parsed.ConsentVersion = config.ConsentVersion
+			parsed.ConsentTime = parsed.SubmitTime
+		} else {
+			problems = append(problems, localize(language, msgMissingConsent))
+		}
+	}
 
-	for i := 1; i <= 5; i++ {
-		if parsedTeam := parseTeam(data, language, i); parsedTeam != nil {
+	if blocked(config.Blocklist, parsed.Email, parsed.Club) {
+		config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"email": parsed.Email,
+			"club":  parsed.Club,
+		})).Warn("Submission matches blocklist")
+		problems = append(problems, localize(language, msgBlocked))
+	}
+
+	if parsed.Email != "" {
+		if address, mailErr := mail.ParseAddress(parsed.Email); mailErr != nil {
+			problems = append(problems, localize(language, msgInvalidEmail, parsed.Email))
+		} else if !allowedDomain(address.Address, config.AllowedEmailDomains) {
+			problems = append(problems, localize(language, msgEmailDomain, parsed.Email))
+		}
+	}
+
+	if second := parsed.SecondContact; second != nil && second.Email != "" {
+		if _, mailErr := mail.ParseAddress(second.Email); mailErr != nil {
+			problems = append(problems, localize(language, msgInvalidEmail, second.Email))
+		}
+	}
+
+	// this is not how it used to work but since the sign-up season typically runs from
+	// April to August, this should be safe enough when no season is configured
+	parsed.Year = parsed.SubmitTime.Year()
+	if config.SeasonYear != 0 {
+		if config.StrictSeason && parsed.Year != config.SeasonYear {
+			problems = append(problems, localize(language, msgWrongSeason, parsed.Year, config.SeasonYear))
+		}
+		parsed.Year = config.SeasonYear
+	}
+
+	if config.SeasonStartMonth != 0 {
+		parsed.Season = seasonLabel(parsed.SubmitTime, config.SeasonStartMonth)
+	}
+
+	for i := 1; i <= config.MaxTeams; i++ {
+		parsedTeam, warnings, teamErr := parseTeam(data, language, config, i)
+		parsed.Warnings = append(parsed.Warnings, warnings...)
+		if teamErr != nil {
+			problems = append(problems, teamErr.Error())
+		} else if parsedTeam != nil {
 			parsed.Teams = append(parsed.Teams, *parsedTeam)
 		}
 	}
 
-	if err == nil && len(parsed.Teams) == 0 {
-		err = errors.New("Subscription contains no teams")
+	if duplicate, ok := duplicateTeamName(parsed.Teams); ok {
+		config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"team": duplicate,
+			"club": parsed.Club,
+		})).Warn("Submission has the same team twice")
+		if config.UniqueTeamNames {
+			problems = append(problems, localize(language, msgDuplicateTeam, duplicate))
+		} else {
+			parsed.Warnings = append(parsed.Warnings, localize(language, msgDuplicateTeam, duplicate))
+		}
+	}
+
+	if config.ContiguousTeams {
+		// a gap in the slots usually means a field of the form was renamed
+		for i, team := range parsed.Teams {
+			if team.Slot != i+1 {
+				problems = append(problems, localize(language, msgTeamGap, i+1))
+				break
+			}
+		}
+	}
+
+	if config.SortTeams {
+		sortTeams(parsed.Teams)
+	}
+
+	if extra := teamsBeyondSlots(data, config); len(extra) > 0 {
+		config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"slots":   config.MaxTeams,
+			"dropped": extra,
+			"strict":  config.StrictTeamSlots,
+			"club":    parsed.Club,
+		})).Warn("Submission has more teams than slots")
+		if config.StrictTeamSlots {
+			problems = append(problems, localize(language, msgTooManyTeams, config.MaxTeams))
+		} else {
+			parsed.Warnings = append(parsed.Warnings, localize(language, msgTeamsDropped, config.MaxTeams))
+		}
+	}
+
+	if len(parsed.Teams) == 0 {
+		problems = append(problems, localize(language, msgNoTeams))
+	} else {
+		// types are translated by now so the rule is the same for both languages
+		for _, required := range config.RequiredTypes {
+			if !hasType(parsed.Teams, required) {
+				problems = append(problems, localize(language, msgMissingType, required))
+			}
+		}
+
+		limited := make([]string, 0, len(config.MaxTeamsPerType))
+		for teamType := range config.MaxTeamsPerType {
+			limited = append(limited, teamType)
+		}
+		sort.Strings(limited)
+
+		for _, teamType := range limited {
+			if max := config.MaxTeamsPerType[teamType]; countType(parsed.Teams, teamType) > max {
+				problems = append(problems, localize(language, msgTooManyOfType, max, teamType))
+			}
+		}
 	}
 
+	err = problems.err()
+
 	return
 }
 
-func parseTeam(data map[string]string, language language, index int) (parsed *team) {
-	if name := data[fmt.Sprintf("team%d-name", index)]; name != "" {
-		parsed = &team{
-			Name:  name,
-			Type:  data[fmt.Sprintf("team%d-type", index)],
-			Level: data[fmt.Sprintf("team%d-level", index)],
+// nameParticles are the lower case words that belong to the surname when they precede it,
+// such as in "Jan van der Berg"
+var nameParticles = map[string]bool{
+	"van": true, "der": true, "den": true, "de": true, "het": true, "'t": true, "ter": true,
+	"ten": true, "te": true, "in": true, "op": true, "von": true, "le": true, "la": true, "du": true,
+}
+
+// splitName splits a full name into the given name and the surname: the last word together with
+// the particles before it, "Jan van der Berg" becomes "Jan" and "van der Berg". A single word is
+// only a given name.
+func splitName(fullName string) (name string, surname string) {
+	words := strings.Fields(fullName)
+	if len(words) < 2 {
+		return fullName, ""
+	}
+
+	start := len(words) - 1
+	// at least one word remains the given name
+	for start > 1 && nameParticles[strings.ToLower(words[start-1])] {
+		start--
+	}
+
+	return strings.Join(words[:start], " "), strings.Join(words[start:], " ")
+}
+
+// seasonLabel names the season t falls in when seasons start in month start, with start August
+// both 1 September 2024 and 1 March 2025 are in "2024-2025"
+func seasonLabel(t time.Time, start time.Month) string {
+	year := t.Year()
+	if t.Month() < start {
+		year--
+	}
+
+	return fmt.Sprintf("%d-%d", year, year+1)
+}
+
+// consented reports whether a consent checkbox is checked, wordpress sends the label or 1 for a
+// checked box and nothing for an unchecked one
+func consented(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false", "no", "nee", "off":
+		return false
+	}
+
+	return true
+}
+
+// blocked reports whether any of the values is on the blocklist, ignoring case
+func blocked(blocklist []string, values ...string) bool {
+	for _, value := range values {
+		for _, entry := range blocklist {
+			if value != "" && strings.EqualFold(strings.TrimSpace(value), entry) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func hasType(teams []Team, teamType string) bool {
+	return countType(teams, teamType) > 0
+}
+
+// countType returns the number of teams of teamType
+func countType(teams []Team, teamType string) (count int) {
+	for _, team := range teams {
+		if team.Type == teamType {
+			count++
+		}
+	}
+
+	return
+}
+
+// allowedDomain reports whether the domain of address is one of domains, any domain is allowed when domains is empty
+func allowedDomain(address string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	domain := address[strings.LastIndex(address, "@")+1:]
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// knownLevel reports whether level is one of the Dutch levels
+func knownLevel(level string) bool {
+	for _, dutch := range enLevels {
+		if dutch == level {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeTeamName trims a team name and collapses the whitespace within it, the case is kept
+// for display
+func normalizeTeamName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// teamNameKey compares team names, "Team A" and "team  a" are the same team
+func teamNameKey(name string) string {
+	return strings.ToLower(normalizeTeamName(name))
+}
+
+// duplicateTeamName returns the first team name that occurs twice
+func duplicateTeamName(teams []Team) (string, bool) {
+	seen := make(map[string]struct{}, len(teams))
+	for _, team := range teams {
+		key := teamNameKey(team.Name)
+		if _, ok := seen[key]; ok {
+			return team.Name, true
+		}
+		seen[key] = struct{}{}
+	}
+
+	return "", false
+}
+
+// sortTeams orders the teams by type and name and renumbers their slots to match
+func sortTeams(teams []Team) {
+	sort.SliceStable(teams, func(i, j int) bool {
+		if a, b := strings.ToLower(teams[i].Type), strings.ToLower(teams[j].Type); a != b {
+			return a < b
+		}
+		return teamNameKey(teams[i].Name) < teamNameKey(teams[j].Name)
+	})
+
+	for i := range teams {
+		teams[i].Slot = i + 1
+	}
+}
+
+// teamsBeyondSlots returns the slots above MaxTeams that have a team name, which parseData does not read
+func teamsBeyondSlots(data map[string]string, config Config) (slots []int) {
+	for key, value := range data {
+		var slot int
+		if value == "" {
+			continue
+		}
+		// the key must be the complete field name, not just start like it
+		if _, err := fmt.Sscanf(key, config.Fields.TeamName, &slot); err != nil || fmt.Sprintf(config.Fields.TeamName, slot) != key {
+			continue
+		}
+		if slot > config.MaxTeams {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Ints(slots)
+
+	return
+}
+
+// parseTeam reads the team in slot index, returning nil when the slot is empty. Accepted problems,
+// such as a value stored as unknown, are returned as warnings.
+func parseTeam(data map[string]string, language Language, config Config, index int) (parsed *Team, warnings []string, err error) {
+	fields := config.Fields
+	if name := data[fmt.Sprintf(fields.TeamName, index)]; name != "" {
+		parsed = &Team{
+			Slot:  index,
+			Name:  normalizeTeamName(name),
+			Type:  data[fmt.Sprintf(fields.TeamType, index)],
+			Level: data[fmt.Sprintf(fields.TeamLevel, index)],
+			Poule: data[fmt.Sprintf(fields.TeamPoule, index)],
+		}
+
+		var unknownDays []string
+		parsed.Availability, unknownDays = parseAvailability(data[fmt.Sprintf(fields.TeamAvailability, index)], config.Days)
+		if len(unknownDays) > 0 {
+			if config.StrictAvailability {
+				return nil, nil, errors.New(localize(language, msgInvalidDays, strings.Join(unknownDays, ", "), index))
+			}
+
+			config.Logger.WithField("days", unknownDays).Warn("Dropping unknown availability days")
+			unknownValues.Add(1)
+			warnings = append(warnings, localize(language, msgInvalidDays, strings.Join(unknownDays, ", "), index))
+		}
+
+		if parsed.Type == "" && parsed.Level == "" {
+			return nil, nil, errors.New(localize(language, msgTeamIncomplete, index))
 		}
 
 		// convert English terms to Dutch equivalents
 		if language == en {
-			switch parsed.Type {
-			case "Men":
-				parsed.Type = "Heren"
-			case "Women":
-				parsed.Type = "Dames"
-			default:
-				parsed.Type = "Onbekend, check registration-handler"
+			parsed.OriginalType = parsed.Type
+			parsed.OriginalLevel = parsed.Level
+			parsed.Type = translate("type", enTypes, config.TypePatterns, parsed.Type, config.Unknown)
+			parsed.Level = translateLevel(parsed.Level, language, config.LevelPatterns, config.Unknown)
+
+			if parsed.Type == config.Unknown && parsed.OriginalType != "" {
+				warnings = append(warnings, localize(language, msgUnknownValue, parsed.OriginalType, index))
+			}
+			if parsed.Level == config.Unknown && parsed.OriginalLevel != "" {
+				warnings = append(warnings, localize(language, msgUnknownValue, parsed.OriginalLevel, index))
+			}
+		} else {
+			parsed.Level = translateLevel(parsed.Level, language, config.LevelPatterns, config.Unknown)
+
+			var unknown []string
+			if unknown, err = checkDutch(parsed, config.DutchValidation, language, index); err != nil {
+				return nil, nil, err
+			}
+			for _, value := range unknown {
+				warnings = append(warnings, localize(language, msgUnknownValue, value, index))
+			}
+		}
+
+		if !regionOffered(config.RegionalLevels, parsed.Type, parsed.Level) {
+			return nil, nil, errors.New(localize(language, msgRegionNotOffered, parsed.Level, parsed.Type))
+		}
+
+		if len(config.LevelCodes) > 0 {
+			var ok bool
+			if parsed.LevelCode, ok = config.LevelCodes[parsed.Level]; !ok {
+				if config.StrictLevelCodes {
+					return nil, nil, errors.New(localize(language, msgUnknownValue, parsed.Level, index))
+				}
+
+				config.Logger.WithField("level", parsed.Level).Warn("Level has no code")
 			}
+		}
+
+		if !validPoule(config.Poules, parsed.Type, parsed.Poule) {
+			if config.StrictPoules {
+				return nil, nil, errors.New(localize(language, msgInvalidPoule, parsed.Poule, index))
+			}
+
+			warnings = append(warnings, localize(language, msgInvalidPoule, parsed.Poule, index))
+
+			config.Logger.WithFields(log.Fields(map[string]interface{}{
+				"type":  parsed.Type,
+				"poule": parsed.Poule,
+			})).Warn("Unknown poule")
+			unknownValues.Add(1)
+			parsed.Poule = config.Unknown
+		}
+	}
+
+	return
+}
+
+// parseAvailability normalizes comma separated day codes and returns the codes that are not
+// one of days, which are left out. Without days every code is accepted.
+func parseAvailability(value string, days []string) (availability string, unknown []string) {
+	var valid []string
+	for _, day := range strings.Split(value, ",") {
+		if day = strings.TrimSpace(day); day == "" {
+			continue
+		}
 
-			switch parsed.Level {
-			case "National":
-				parsed.Level = "Bond 2"
-			case "Regional High":
-				parsed.Level = "Regio 1"
-			case "Regional Low":
-				parsed.Level = "Regio 3-4"
-			default:
-				parsed.Level = "Onbekend, check registration-handler"
+		known := len(days) == 0
+		for _, d := range days {
+			if strings.EqualFold(d, day) {
+				known, day = true, d
+				break
 			}
 		}
+
+		if known {
+			valid = append(valid, day)
+		} else {
+			unknown = append(unknown, day)
+		}
+	}
+
+	return strings.Join(valid, ","), unknown
+}
+
+// validPoule reports whether poule is one of the poules of teamType, an empty poule or
+// a type without configured poules accepts anything
+func validPoule(poules map[string][]string, teamType string, poule string) bool {
+	valid, ok := poules[teamType]
+	if poule == "" || !ok {
+		return true
+	}
+
+	for _, p := range valid {
+		if p == poule {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkDutch compares the type and level of a Dutch form with the values the English form translates to,
+// returning the unknown values in warn mode
+func checkDutch(parsed *Team, mode string, language Language, index int) (unknown []string, err error) {
+	if mode != "warn" && mode != "strict" {
+		return
+	}
+
+	checks := []struct {
+		value string
+		known map[string]string
+	}{
+		{parsed.Type, enTypes},
+		{parsed.Level, enLevels},
+	}
+
+	for _, check := range checks {
+		value := check.value
+		if value == "" || isTranslation(check.known, value) {
+			continue
+		}
+
+		if mode == "strict" {
+			return nil, errors.New(localize(language, msgUnknownValue, value, index))
+		}
+
+		unknownValues.Add(1)
+		log.WithFields(log.Fields(map[string]interface{}{
+			"value": value,
+			"team":  index,
+		})).Warn("Unknown value on Dutch form")
+		unknown = append(unknown, value)
 	}
 
 	return
 }
 
-func trim(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+func isTranslation(table map[string]string, value string) bool {
+	for _, translated := range table {
+		if translated == value {
+			return true
+		}
 	}
 
-	return s[:maxLen]
+	return false
+}
+
+// translate looks up the Dutch equivalent of value, first exactly and then by the patterns in
+// order. An untranslatable value is logged together with the options the form should have sent
+// before it is replaced by unknown.
+func translate(field string, table map[string]string, patterns []ValuePattern, value string, unknown string) string {
+	if translated, ok := table[value]; ok {
+		return translated
+	}
+
+	for _, pattern := range patterns {
+		if pattern.Pattern.MatchString(value) {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"field":    field,
+				"value":    value,
+				"pattern":  pattern.Pattern.String(),
+				"storedAs": pattern.Value,
+			})).Info("Translated value by pattern")
+			return pattern.Value
+		}
+	}
+
+	known := make([]string, 0, len(table))
+	for option := range table {
+		known = append(known, option)
+	}
+	sort.Strings(known)
+
+	unknownValues.Add(1)
+	log.WithFields(log.Fields(map[string]interface{}{
+		"field":    field,
+		"value":    value,
+		"storedAs": unknown,
+		"known":    known,
+	})).Warn("Unknown value, storing as unknown")
+
+	return unknown
 }
@@ -0,0 +1,138 @@
+package form
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTranslateTeamTypes(t *testing.T) {
+	tests := []struct {
+		english string
+		dutch   string
+	}{
+		{"Men", "Heren"},
+		{"Women", "Dames"},
+		{"Mixed", "Gemengd"},
+		{"Youth Boys", "Jongens"},
+		{"Youth Girls", "Meisjes"},
+		{"Veterans", defaultUnknown},
+	}
+
+	for _, test := range tests {
+		if dutch := translate("type", enTypes, nil, test.english, defaultUnknown); dutch != test.dutch {
+			t.Errorf("expected %q to translate to %q, got %q", test.english, test.dutch, dutch)
+		}
+
+		if test.dutch == defaultUnknown {
+			continue
+		}
+
+		// the Dutch form offers the translations directly
+		if _, err := checkDutch(&Team{Type: test.dutch}, "strict", nl, 1); err != nil {
+			t.Errorf("expected %q to be accepted on the Dutch form, got %v", test.dutch, err)
+		}
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		fullName string
+		name     string
+		surname  string
+	}{
+		{"Jan Jansen", "Jan", "Jansen"},
+		{"Jan Peter Jansen", "Jan Peter", "Jansen"},
+		{"Jan van der Berg", "Jan", "van der Berg"},
+		{"Anne de Vries", "Anne", "de Vries"},
+		{"Jan  Jansen ", "Jan", "Jansen"},
+		{"Jan", "Jan", ""},
+		// a particle is not a given name on its own
+		{"Van Dijk", "Van", "Dijk"},
+	}
+
+	for _, test := range tests {
+		if name, surname := splitName(test.fullName); name != test.name || surname != test.surname {
+			t.Errorf("expected %q to be split into %q and %q, got %q and %q",
+				test.fullName, test.name, test.surname, name, surname)
+		}
+	}
+}
+
+func TestNormalizeTeamName(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalized string
+		key        string
+	}{
+		{"Team A", "Team A", "team a"},
+		{"  team   a ", "team a", "team a"},
+		{"Heren\t1", "Heren 1", "heren 1"},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		if normalized := normalizeTeamName(test.name); normalized != test.normalized {
+			t.Errorf("expected %q to be stored as %q, got %q", test.name, test.normalized, normalized)
+		}
+		if key := teamNameKey(test.name); key != test.key {
+			t.Errorf("expected %q to compare as %q, got %q", test.name, test.key, key)
+		}
+	}
+}
+
+func TestDuplicateTeamName(t *testing.T) {
+	if _, ok := duplicateTeamName([]Team{{Name: "Team A"}, {Name: "Team B"}}); ok {
+		t.Error("expected different teams not to be duplicates")
+	}
+	if name, ok := duplicateTeamName([]Team{{Name: "Team A"}, {Name: "team  a"}}); !ok || name != "team  a" {
+		t.Errorf("expected team  a to duplicate Team A, got %q", name)
+	}
+}
+
+func TestSeasonLabel(t *testing.T) {
+	tests := []struct {
+		t      time.Time
+		start  time.Month
+		season string
+	}{
+		{time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC), time.August, "2024-2025"},
+		{time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.August, "2024-2025"},
+		{time.Date(2025, time.July, 31, 23, 59, 0, 0, time.UTC), time.August, "2024-2025"},
+		{time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC), time.August, "2025-2026"},
+		{time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), time.January, "2025-2026"},
+	}
+
+	for _, test := range tests {
+		if season := seasonLabel(test.t, test.start); season != test.season {
+			t.Errorf("%v with seasons starting in %s: expected %s, got %s", test.t, test.start, test.season, season)
+		}
+	}
+}
+
+func TestTranslateByPattern(t *testing.T) {
+	patterns := []ValuePattern{
+		{regexp.MustCompile(`^Men\b`), "Heren"},
+		{regexp.MustCompile(`(?i)women`), "Dames"},
+		// never reached for women's teams, the patterns are tried in order
+		{regexp.MustCompile(`(?i)men`), "Gemengd"},
+	}
+
+	tests := []struct {
+		english string
+		dutch   string
+	}{
+		// an exact match wins over the patterns
+		{"Men", "Heren"},
+		{"Men (recreational)", "Heren"},
+		{"Youth women", "Dames"},
+		{"Recreational men", "Gemengd"},
+		{"Veterans", defaultUnknown},
+	}
+
+	for _, test := range tests {
+		if dutch := translate("type", enTypes, patterns, test.english, defaultUnknown); dutch != test.dutch {
+			t.Errorf("expected %q to translate to %q, got %q", test.english, test.dutch, dutch)
+		}
+	}
+}
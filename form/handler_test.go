@@ -0,0 +1,1872 @@
+package form_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"expvar"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+// submitTime is the fake time of the handlers of the tests, in the middle of the sign-up season
+var submitTime = time.Date(2018, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+// validMessage is a complete submission of the Dutch form with a single team
+func validMessage() form.Message {
+	return form.Message{
+		Title: "Inschrijven teams",
+		Data: map[string]string{
+			"contact-club":    "SBC2000",
+			"contact-name":    "Jan",
+			"contact-surname": "Jansen",
+			"contact-email":   "jan@example.com",
+			"contact-phone":   "0612345678",
+			"team1-name":      "Heren 1",
+			"team1-type":      "Heren",
+			"team1-level":     "Regio 1",
+		},
+	}
+}
+
+// newHandler creates a handler using the fake clock and IDs, the caller closes it
+func newHandler(t *testing.T, store form.Store, config form.Config) (form.Handler, *formtest.Clock) {
+	clock := &formtest.Clock{T: submitTime}
+	h, err := form.NewHandlerWith(store, clock, &formtest.IDs{IDs: []string{"000001", "000002", "000003"}}, config)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	return h, clock
+}
+
+// slowStore takes a while to save, which leaves time for a concurrent submission to race
+type slowStore struct {
+	*formtest.MemoryStore
+}
+
+func (s slowStore) SaveRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, maxRegistrations int) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.MemoryStore.SaveRegistration(ctx, registration, subscriptionID, language, maxRegistrations)
+}
+
+func TestConcurrentDoubleSubmitIsStoredOnce(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, slowStore{store}, form.Config{})
+	defer h.Close()
+
+	var wg sync.WaitGroup
+	results := make([]form.Result, 2)
+	errs := make([]error, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = h.Handle(context.Background(), validMessage())
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected a single registration, got %d", store.Registrations())
+	}
+	if results[0].SubscriptionID != results[1].SubscriptionID {
+		t.Errorf("expected both submits to get the same subscription ID, got %s and %s",
+			results[0].SubscriptionID, results[1].SubscriptionID)
+	}
+}
+
+func TestHandleStoresRegistration(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, language, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatalf("expected the registration in the store: %v", err)
+	}
+	if registration.Club != "SBC2000" || registration.Email != "jan@example.com" || language.Code() != "NL" {
+		t.Errorf("unexpected registration %+v in %s", registration, language.Code())
+	}
+	if len(registration.Teams) != 1 || registration.Teams[0].Name != "Heren 1" {
+		t.Errorf("expected the team Heren 1, got %+v", registration.Teams)
+	}
+}
+
+func TestHandleSkipsExistingSubscriptionIDs(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	ctx := context.Background()
+	if _, err := store.SaveRegistration(ctx, form.Registration{Club: "Other"}, "000001", "NL", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if result.SubscriptionID != "000002" {
+		t.Errorf("expected the ID in the store to be skipped, got %s", result.SubscriptionID)
+	}
+}
+
+func TestHandleRejectsEmptySubmission(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+	defer h.Close()
+
+	for _, data := range []map[string]string{nil, {}} {
+		_, err := h.Handle(context.Background(), form.Message{Title: "Inschrijven teams", Data: data})
+		if problems, ok := err.(form.ValidationErrors); !ok || len(problems) != 1 {
+			t.Errorf("expected a single validation error for %v, got %v", data, err)
+		}
+	}
+}
+
+func TestHandleCountsIgnoredTitles(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ignored := expvar.Get("ignored_messages").(*expvar.Int)
+	before := ignored.Value()
+
+	message := validMessage()
+	message.Title = "Renamed form"
+	result, err := h.Handle(context.Background(), message)
+	if err != nil || result.SubscriptionID != "" {
+		t.Fatalf("expected the message to be ignored, got %+v and %v", result, err)
+	}
+
+	if after := ignored.Value(); after != before+1 {
+		t.Errorf("expected the ignored counter to go from %d to %d, got %d", before, before+1, after)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestInvalidSubmissionIsDeadLettered(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ctx := context.Background()
+	if _, err := h.Handle(ctx, validMessage()); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if len(store.DeadLetters) != 0 {
+		t.Fatalf("expected no dead letter for a valid submission, got %d", len(store.DeadLetters))
+	}
+
+	message := validMessage()
+	message.Data["contact-club"] = "Other club"
+	delete(message.Data, "contact-email")
+	if _, err := h.Handle(ctx, message); err == nil {
+		t.Fatal("expected the submission without email to be rejected")
+	}
+
+	if len(store.DeadLetters) != 1 {
+		t.Fatalf("expected a dead letter for the invalid submission, got %d", len(store.DeadLetters))
+	}
+	if letter := store.DeadLetters[0]; letter.Message.Data["contact-club"] != "Other club" || letter.Cause == nil {
+		t.Errorf("expected the message and its cause, got %+v", letter)
+	}
+}
+
+func TestHandleStoresOptionalNotes(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ctx := context.Background()
+	for _, notes := range []string{"Graag op zaterdag", ""} {
+		message := validMessage()
+		message.Data["contact-club"] = "Club " + notes
+		if notes != "" {
+			message.Data["contact-notes"] = notes
+		}
+
+		result, err := h.Handle(ctx, message)
+		if err != nil {
+			t.Fatalf("Handle failed for notes %q: %v", notes, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.Notes != notes {
+			t.Errorf("expected notes %q, got %q", notes, registration.Notes)
+		}
+	}
+}
+
+func TestHandleReturnsTeamCount(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team2-name"] = "Dames 1"
+	message.Data["team2-type"] = "Dames"
+	message.Data["team2-level"] = "Regio 1"
+
+	result, err := h.Handle(context.Background(), message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if result.Teams != 2 {
+		t.Errorf("expected 2 stored teams, got %d", result.Teams)
+	}
+}
+
+func TestHandleRejectsTeamWithoutTypeAndLevel(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team1-type"] = ""
+	message.Data["team1-level"] = ""
+	if _, err := h.Handle(context.Background(), message); err == nil {
+		t.Error("expected a team without type and level to be rejected")
+	}
+
+	// a single missing value is not a reason to reject the team
+	message = validMessage()
+	message.Data["team1-level"] = ""
+	result, err := h.Handle(context.Background(), message)
+	if err != nil {
+		t.Fatalf("expected a team without level to be accepted, got %v", err)
+	}
+	if result.Teams != 1 || store.Registrations() != 1 {
+		t.Errorf("expected a single stored team, got %d", result.Teams)
+	}
+}
+
+func TestHandleStoresSeasonYear(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		seasonYear int
+		expected   int
+	}{
+		{0, submitTime.Year()},
+		{2019, 2019},
+	} {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{SeasonYear: test.seasonYear})
+
+		result, err := h.Handle(ctx, validMessage())
+		h.Close()
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.Year != test.expected {
+			t.Errorf("expected year %d with season %d, got %d", test.expected, test.seasonYear, registration.Year)
+		}
+	}
+}
+
+func TestHandleRejectsOtherSeason(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{SeasonYear: 2019, StrictSeason: true})
+	defer h.Close()
+
+	if _, err := h.Handle(context.Background(), validMessage()); err == nil {
+		t.Error("expected a submission outside the configured season to be rejected")
+	}
+}
+
+func TestHandleReportsAllProblems(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	delete(message.Data, "contact-name")
+	message.Data["contact-email"] = "not an address"
+	delete(message.Data, "team1-name")
+
+	_, err := h.Handle(context.Background(), message)
+	problems, ok := err.(form.ValidationErrors)
+	if !ok {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	if len(problems) != 3 {
+		t.Errorf("expected the name, email and teams to be reported, got %q", problems)
+	}
+}
+
+func TestHandleWithCustomFieldMapping(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{Fields: form.FieldMapping{
+		Club:      "vereniging",
+		Email:     "email",
+		TeamName:  "ploeg-%d",
+		TeamType:  "ploeg-%d-soort",
+		TeamLevel: "ploeg-%d-niveau",
+	}})
+	defer h.Close()
+
+	message := form.Message{
+		Title: "Inschrijven teams",
+		Data: map[string]string{
+			"vereniging":      "SBC2000",
+			"contact-name":    "Jan",
+			"contact-surname": "Jansen",
+			"email":           "jan@example.com",
+			"contact-phone":   "0612345678",
+			"ploeg-1":         "Heren 1",
+			"ploeg-1-soort":   "Heren",
+			"ploeg-1-niveau":  "Regio 1",
+		},
+	}
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Club != "SBC2000" || registration.Email != "jan@example.com" {
+		t.Errorf("expected the renamed contact fields to be read, got %+v", registration)
+	}
+	if len(registration.Teams) != 1 || registration.Teams[0].Type != "Heren" {
+		t.Errorf("expected the renamed team fields to be read, got %+v", registration.Teams)
+	}
+
+	// the default names are not read anymore
+	if _, err = h.Handle(ctx, validMessage()); err == nil {
+		t.Error("expected a submission with the default field names to be rejected")
+	}
+}
+
+func TestHandleKeepsTeamSlots(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team3-name"] = "Dames 1"
+	message.Data["team3-type"] = "Dames"
+	message.Data["team3-level"] = "Regio 1"
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registration.Teams) != 2 || registration.Teams[0].Slot != 1 || registration.Teams[1].Slot != 3 {
+		t.Errorf("expected the teams in slots 1 and 3, got %+v", registration.Teams)
+	}
+}
+
+func TestHandleDuplicateWindow(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, clock := newHandler(t, store, form.Config{DuplicateWindow: time.Hour})
+	defer h.Close()
+
+	ctx := context.Background()
+	first, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	// a double click, the surrounding whitespace does not make it another submission
+	clock.Advance(time.Minute)
+	message := validMessage()
+	message.Data["contact-club"] = " SBC2000 "
+	again, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if again.SubscriptionID != first.SubscriptionID || store.Registrations() != 1 {
+		t.Errorf("expected the earlier subscription %s within the window, got %s", first.SubscriptionID, again.SubscriptionID)
+	}
+
+	clock.Advance(time.Hour)
+	later, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if later.SubscriptionID == first.SubscriptionID || store.Registrations() != 2 {
+		t.Errorf("expected a new registration after the window, got %s", later.SubscriptionID)
+	}
+}
+
+func TestHandleDuplicateConflictMode(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{DuplicateMode: "conflict"})
+	defer h.Close()
+
+	ctx := context.Background()
+	first, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	again, err := h.Handle(ctx, validMessage())
+	if err != form.ErrDuplicate || again.SubscriptionID != first.SubscriptionID {
+		t.Errorf("expected ErrDuplicate with subscription %s, got %v and %s", first.SubscriptionID, err, again.SubscriptionID)
+	}
+}
+
+func TestHandleAllowedEmailDomains(t *testing.T) {
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{AllowedEmailDomains: []string{"example.com"}})
+	defer h.Close()
+
+	ctx := context.Background()
+	if _, err := h.Handle(ctx, validMessage()); err != nil {
+		t.Errorf("expected an address of an allowed domain to be accepted, got %v", err)
+	}
+
+	message := validMessage()
+	message.Data["contact-club"] = "Other club"
+	message.Data["contact-email"] = "jan@example.org"
+	_, err := h.Handle(ctx, message)
+	if problems, ok := err.(form.ValidationErrors); !ok || len(problems) != 1 {
+		t.Errorf("expected an address of another domain to be rejected, got %v", err)
+	}
+}
+
+func TestHandleHoneypot(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{HoneypotField: "website"})
+	defer h.Close()
+
+	ctx := context.Background()
+	message := validMessage()
+	message.Data["website"] = ""
+	if _, err := h.Handle(ctx, message); err != nil || store.Registrations() != 1 {
+		t.Fatalf("expected a submission with an empty honeypot to be stored, got %v", err)
+	}
+
+	// the bot gets the same answer as a club, but nothing is stored
+	message = validMessage()
+	message.Data["contact-club"] = "Spam club"
+	message.Data["website"] = "http://spam.example.com"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("expected a filled honeypot to be answered as a success, got %v", err)
+	}
+	if result.SubscriptionID == "" || result.Teams != 1 || result.Message == "" {
+		t.Errorf("expected a result like that of a stored form, got %+v", result)
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected the spam not to be stored, got %d registrations", store.Registrations())
+	}
+
+	// invalid spam is answered like any invalid submission, without a dead letter
+	delete(message.Data, "contact-email")
+	if _, err = h.Handle(ctx, message); err == nil {
+		t.Error("expected invalid spam to be rejected")
+	}
+	if len(store.DeadLetters) != 0 {
+		t.Errorf("expected no dead letter for spam, got %d", len(store.DeadLetters))
+	}
+}
+
+func TestHoneypotDecoyDoesNotTakeTheNextSequentialID(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	clock := &formtest.Clock{T: submitTime}
+	h, err := form.NewHandlerWith(store, clock, form.NewSequentialIDs(2018), form.Config{HoneypotField: "website"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer h.Close()
+
+	ctx := context.Background()
+	spam := validMessage()
+	spam.Data["contact-club"] = "Spam club"
+	spam.Data["website"] = "http://spam.example.com"
+	decoy, err := h.Handle(ctx, spam)
+	if err != nil {
+		t.Fatalf("expected a filled honeypot to be answered as a success, got %v", err)
+	}
+
+	stored, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if stored.SubscriptionID != "18-0001" {
+		t.Errorf("expected the club to get the first ID of the season, got %s", stored.SubscriptionID)
+	}
+	if !strings.HasPrefix(decoy.SubscriptionID, "18-") || decoy.SubscriptionID < "18-5000" {
+		t.Errorf("expected a decoy ID of the season from 5000, got %s", decoy.SubscriptionID)
+	}
+}
+
+func TestHandlePoules(t *testing.T) {
+	poules := map[string][]string{"Heren": {"A", "B"}}
+	ctx := context.Background()
+
+	tests := []struct {
+		poule    string
+		strict   bool
+		stored   string
+		rejected bool
+	}{
+		{"A", false, "A", false},
+		{"A", true, "A", false},
+		{"Z", false, "Onbekend", false},
+		{"Z", true, "", true},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{Poules: poules, StrictPoules: test.strict, Unknown: "Onbekend"})
+
+		message := validMessage()
+		message.Data["team1-poule"] = test.poule
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("poule %s, strict %t: expected a validation error, got %v", test.poule, test.strict, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("poule %s, strict %t: Handle failed: %v", test.poule, test.strict, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if poule := registration.Teams[0].Poule; poule != test.stored {
+			t.Errorf("poule %s, strict %t: expected %q to be stored, got %q", test.poule, test.strict, test.stored, poule)
+		}
+	}
+}
+
+func TestRegenerateID(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	// the third ID is taken by the regenerated subscription, the fourth was released by it
+	ids := &formtest.IDs{IDs: []string{"000001", "000002", "000002", "000001"}}
+	h, err := form.NewHandlerWith(store, &formtest.Clock{T: submitTime}, ids, form.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer h.Close()
+
+	ctx := context.Background()
+	if _, err = h.Handle(ctx, validMessage()); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	regenerated, err := h.RegenerateID(ctx, "000001")
+	if err != nil {
+		t.Fatalf("RegenerateID failed: %v", err)
+	}
+	if regenerated.SubscriptionID != "000002" {
+		t.Fatalf("expected the new ID 000002, got %s", regenerated.SubscriptionID)
+	}
+	if exists, _ := store.SubscriptionIDExists(ctx, "000001"); exists {
+		t.Error("expected the old ID to be gone from the store")
+	}
+	if _, _, err = store.Registration(ctx, "000002"); err != nil {
+		t.Errorf("expected the registration under the new ID: %v", err)
+	}
+
+	// a resubmission within the duplicate window gets the ID that exists
+	again, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if again.SubscriptionID != "000002" {
+		t.Errorf("expected the resubmission to return 000002, got %s", again.SubscriptionID)
+	}
+
+	message := validMessage()
+	message.Data["contact-club"] = "Kinheim"
+	other, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if other.SubscriptionID != "000001" {
+		t.Errorf("expected the new ID to be reserved and the old one released, got %s", other.SubscriptionID)
+	}
+
+	if _, err = h.RegenerateID(ctx, "999999"); err != form.ErrSubscriptionNotFound {
+		t.Errorf("expected ErrSubscriptionNotFound for an unknown subscription, got %v", err)
+	}
+}
+
+func TestHandleTreatsBlankValuesAsMissing(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	for _, blank := range []string{" ", "\t", " \t\n"} {
+		message := validMessage()
+		message.Data["contact-name"] = blank
+		message.Data["contact-phone"] = blank
+
+		_, err := h.Handle(context.Background(), message)
+		if problems, ok := err.(form.ValidationErrors); !ok || len(problems) != 2 {
+			t.Errorf("%q: expected the name and phone to be missing, got %v", blank, err)
+		}
+	}
+
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestHandleCountsUnknownValues(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{Unknown: "Onbekend"})
+	defer h.Close()
+
+	unknown := expvar.Get("unknown_values").(*expvar.Int)
+	before := unknown.Value()
+
+	message := validMessage()
+	message.Title = "Sign up teams"
+	message.Data["team1-type"] = "Veterans"
+	message.Data["team1-level"] = "National"
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if unknown.Value() != before+1 {
+		t.Errorf("expected the unknown type to be counted once, counted %d", unknown.Value()-before)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Teams[0].Type != "Onbekend" {
+		t.Errorf("expected the configured sentinel, got %q", registration.Teams[0].Type)
+	}
+}
+
+func TestHandleDutchValidation(t *testing.T) {
+	tests := []struct {
+		mode     string
+		teamType string
+		rejected bool
+		warnings int
+	}{
+		{"strict", "Heren", false, 0},
+		{"strict", "Heeren", true, 0},
+		{"warn", "Heeren", false, 1},
+		{"", "Heeren", false, 0},
+	}
+
+	for _, test := range tests {
+		h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{DutchValidation: test.mode})
+
+		message := validMessage()
+		message.Data["team1-type"] = test.teamType
+		result, err := h.Handle(context.Background(), message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("%s %s: expected a validation error, got %v", test.mode, test.teamType, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s: Handle failed: %v", test.mode, test.teamType, err)
+			continue
+		}
+		if len(result.Warnings) != test.warnings {
+			t.Errorf("%s %s: expected %d warnings, got %q", test.mode, test.teamType, test.warnings, result.Warnings)
+		}
+	}
+}
+
+// blockingStore signals every save on started and waits for release before storing
+type blockingStore struct {
+	*formtest.MemoryStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s blockingStore) SaveRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, maxRegistrations int) (int, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.MemoryStore.SaveRegistration(ctx, registration, subscriptionID, language, maxRegistrations)
+}
+
+// clubMessage is validMessage for another club, so it is not a duplicate of the others
+func clubMessage(club string) form.Message {
+	message := validMessage()
+	message.Data["contact-club"] = club
+	return message
+}
+
+func TestQueueDrainsOnClose(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{QueueSize: 10})
+
+	for _, club := range []string{"SBC2000", "Kinheim", "Huizen"} {
+		result, err := h.Handle(context.Background(), clubMessage(club))
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+		if !result.Queued || result.SubscriptionID != "" {
+			t.Errorf("expected the submission to be queued, got %+v", result)
+		}
+	}
+
+	h.Close()
+	if store.Registrations() != 3 {
+		t.Errorf("expected the queued submissions to be stored on close, got %d", store.Registrations())
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	store := blockingStore{formtest.NewMemoryStore(), make(chan struct{}), make(chan struct{})}
+	h, _ := newHandler(t, store, form.Config{QueueSize: 1})
+
+	ctx := context.Background()
+	if _, err := h.Handle(ctx, clubMessage("SBC2000")); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	// the worker is storing the first submission, the second waits in the queue
+	<-store.started
+	if _, err := h.Handle(ctx, clubMessage("Kinheim")); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if _, err := h.Handle(ctx, clubMessage("Huizen")); err != form.ErrQueueFull {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	go func() {
+		for range store.started {
+		}
+	}()
+	close(store.release)
+	h.Close()
+	close(store.started)
+
+	if store.Registrations() != 2 {
+		t.Errorf("expected the accepted submissions to be stored, got %d", store.Registrations())
+	}
+}
+
+func TestHandleSubmitTimeInLocation(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		now      time.Time
+		location *time.Location
+		date     string
+		year     int
+	}{
+		// half past midnight in Amsterdam is still the evening before in UTC
+		{time.Date(2018, time.May, 1, 22, 30, 0, 0, time.UTC), nil, "2018-05-01", 2018},
+		{time.Date(2018, time.May, 1, 22, 30, 0, 0, time.UTC), amsterdam, "2018-05-02", 2018},
+		{time.Date(2018, time.December, 31, 23, 30, 0, 0, time.UTC), nil, "2018-12-31", 2018},
+		{time.Date(2018, time.December, 31, 23, 30, 0, 0, time.UTC), amsterdam, "2019-01-01", 2019},
+	}
+
+	ctx := context.Background()
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, clock := newHandler(t, store, form.Config{Location: test.location})
+		clock.T = test.now
+
+		result, err := h.Handle(ctx, validMessage())
+		h.Close()
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if date := registration.SubmitTime.Format("2006-01-02"); date != test.date || registration.Year != test.year {
+			t.Errorf("%v in %v: expected %s in %d, got %s in %d", test.now, test.location, test.date, test.year, date, registration.Year)
+		}
+	}
+}
+
+func TestHandleRequiredTypes(t *testing.T) {
+	english := validMessage()
+	english.Title = "Sign up teams"
+	english.Data["team1-type"] = "Men"
+	english.Data["team1-level"] = "National"
+	english.Data["team2-name"] = "Ladies 1"
+	english.Data["team2-type"] = "Women"
+	english.Data["team2-level"] = "National"
+
+	tests := []struct {
+		name     string
+		message  form.Message
+		problems int
+	}{
+		{"only men", validMessage(), 1},
+		{"men and women in English", english, 0},
+	}
+
+	for _, test := range tests {
+		h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{RequiredTypes: []string{"Heren", "Dames"}})
+		_, err := h.Handle(context.Background(), test.message)
+		h.Close()
+
+		if test.problems == 0 {
+			if err != nil {
+				t.Errorf("%s: expected the rule to be satisfied, got %v", test.name, err)
+			}
+			continue
+		}
+		if problems, ok := err.(form.ValidationErrors); !ok || len(problems) != test.problems {
+			t.Errorf("%s: expected %d problems, got %v", test.name, test.problems, err)
+		}
+	}
+}
+
+// scriptedStore fails the saves with the scripted errors in order, a save with a nil error or
+// a commitFailure error is stored
+type scriptedStore struct {
+	*formtest.MemoryStore
+	errs  []error
+	saves int
+}
+
+// commitFailure is returned after storing, like a connection lost while committing
+var commitFailure = &pq.Error{Code: "08006"}
+
+func (s *scriptedStore) SaveRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, maxRegistrations int) (int, error) {
+	var err error
+	if s.saves < len(s.errs) {
+		err = s.errs[s.saves]
+	}
+	s.saves++
+
+	if err != nil && err != commitFailure {
+		return 0, err
+	}
+	if exists, _ := s.MemoryStore.SubscriptionIDExists(ctx, subscriptionID); exists {
+		return 0, &pq.Error{Code: "23505"}
+	}
+
+	teams, saveErr := s.MemoryStore.SaveRegistration(ctx, registration, subscriptionID, language, maxRegistrations)
+	if err != nil {
+		return 0, err
+	}
+	return teams, saveErr
+}
+
+func TestHandleRetriesTransientErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		errs   []error
+		saves  int
+		stored bool
+	}{
+		{"connection reset", []error{driver.ErrBadConn}, 2, true},
+		{"serialization failure", []error{&pq.Error{Code: "40001"}, &pq.Error{Code: "40P01"}}, 3, true},
+		{"retries exhausted", []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, 3, false},
+		{"not null violation", []error{&pq.Error{Code: "23502"}}, 1, false},
+		// the retry finds the registration of the attempt that failed after its commit
+		{"failed commit", []error{commitFailure}, 2, true},
+	}
+
+	for _, test := range tests {
+		store := &scriptedStore{MemoryStore: formtest.NewMemoryStore(), errs: test.errs}
+		h, _ := newHandler(t, store, form.Config{})
+
+		result, err := h.Handle(context.Background(), validMessage())
+		h.Close()
+
+		if store.saves != test.saves {
+			t.Errorf("%s: expected %d attempts, got %d", test.name, test.saves, store.saves)
+		}
+		if test.stored && (err != nil || result.SubscriptionID != "000001" || result.Teams != 1) {
+			t.Errorf("%s: expected the registration to be stored as 000001, got %+v and %v", test.name, result, err)
+		}
+		if !test.stored && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+		if stored := store.Registrations() == 1; stored != test.stored {
+			t.Errorf("%s: expected stored to be %t, got %d registrations", test.name, test.stored, store.Registrations())
+		}
+	}
+}
+
+func TestHandleSeasonCap(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{MaxRegistrations: 2})
+	defer h.Close()
+
+	ctx := context.Background()
+	for _, club := range []string{"SBC2000", "Kinheim"} {
+		if _, err := h.Handle(ctx, clubMessage(club)); err != nil {
+			t.Fatalf("expected %s to register under the cap, got %v", club, err)
+		}
+	}
+
+	if _, err := h.Handle(ctx, clubMessage("Huizen")); err != form.ErrSeasonFull {
+		t.Errorf("expected ErrSeasonFull at the cap, got %v", err)
+	}
+	if store.Registrations() != 2 {
+		t.Errorf("expected 2 registrations, got %d", store.Registrations())
+	}
+}
+
+func TestHandleBlocklist(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{Blocklist: []string{"spam@example.com", "Banned club"}})
+	defer h.Close()
+
+	blockedEmail := validMessage()
+	blockedEmail.Data["contact-email"] = "Spam@example.com"
+	blockedClub := validMessage()
+	blockedClub.Data["contact-club"] = "banned club "
+
+	ctx := context.Background()
+	for _, message := range []form.Message{blockedEmail, blockedClub} {
+		_, err := h.Handle(ctx, message)
+		problems, ok := err.(form.ValidationErrors)
+		if !ok || len(problems) != 1 {
+			t.Errorf("expected %v to be blocked, got %v", message.Data, err)
+			continue
+		}
+		// the club does not learn what matched
+		if strings.Contains(problems[0], "example.com") || strings.Contains(strings.ToLower(problems[0]), "banned") {
+			t.Errorf("expected a generic error, got %q", problems[0])
+		}
+	}
+
+	if _, err := h.Handle(ctx, validMessage()); err != nil {
+		t.Errorf("expected a club that is not listed to register, got %v", err)
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected only the listed submissions to be refused, got %d registrations", store.Registrations())
+	}
+}
+
+func TestHandleKeepsOriginalTypeAndLevel(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Title = "Sign up teams"
+	message.Data["team1-type"] = "Men"
+	message.Data["team1-level"] = "National"
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := registration.Teams[0]
+	if team.Type != "Heren" || team.Level != "Bond 2" {
+		t.Errorf("expected the Dutch type and level to be stored, got %s and %s", team.Type, team.Level)
+	}
+	if team.OriginalType != "Men" || team.OriginalLevel != "National" {
+		t.Errorf("expected the English type and level to be kept, got %s and %s", team.OriginalType, team.OriginalLevel)
+	}
+
+	// the Dutch form has nothing to translate
+	result, err = h.Handle(ctx, clubMessage("Kinheim"))
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if registration, _, err = store.Registration(ctx, result.SubscriptionID); err != nil {
+		t.Fatal(err)
+	}
+	if team = registration.Teams[0]; team.OriginalType != "" || team.OriginalLevel != "" {
+		t.Errorf("expected no original values on the Dutch form, got %s and %s", team.OriginalType, team.OriginalLevel)
+	}
+}
+
+func TestHandleAfterDelete(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ctx := context.Background()
+	first, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if err = h.Delete(ctx, first.SubscriptionID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// the deleted registration is not returned as the earlier result of a resubmission
+	again, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if _, _, err = store.Registration(ctx, again.SubscriptionID); err != nil {
+		t.Errorf("expected the resubmission to be stored, got %v", err)
+	}
+
+	if err = h.Delete(ctx, "999999"); err != form.ErrSubscriptionNotFound {
+		t.Errorf("expected ErrSubscriptionNotFound for an unknown subscription, got %v", err)
+	}
+}
+
+// loadCountingStore counts the loads of all existing subscription IDs
+type loadCountingStore struct {
+	*formtest.MemoryStore
+	loads int
+}
+
+func (s *loadCountingStore) ExistingSubscriptionIDs(ctx context.Context) (map[string]struct{}, error) {
+	s.loads++
+	return s.MemoryStore.ExistingSubscriptionIDs(ctx)
+}
+
+func TestHandleIDLoading(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		mode  string
+		loads int
+	}{
+		{"eager", 1},
+		{"lazy", 0},
+	} {
+		store := &loadCountingStore{MemoryStore: formtest.NewMemoryStore()}
+		for _, id := range []string{"000001", "000002"} {
+			if _, err := store.SaveRegistration(ctx, form.Registration{Club: "Club " + id}, id, "NL", 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		h, _ := newHandler(t, store, form.Config{IDLoading: test.mode})
+		result, err := h.Handle(ctx, validMessage())
+		h.Close()
+		if err != nil {
+			t.Fatalf("%s: Handle failed: %v", test.mode, err)
+		}
+
+		if result.SubscriptionID != "000003" {
+			t.Errorf("%s: expected the existing IDs to be skipped, got %s", test.mode, result.SubscriptionID)
+		}
+		if store.loads != test.loads {
+			t.Errorf("%s: expected %d loads of all IDs, got %d", test.mode, test.loads, store.loads)
+		}
+	}
+
+	if _, err := form.NewHandlerWith(formtest.NewMemoryStore(), &formtest.Clock{}, &formtest.IDs{}, form.Config{IDLoading: "sometimes"}); err == nil {
+		t.Error("expected an unknown ID loading mode to be refused")
+	}
+}
+
+func TestHandleExtraFields(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{ExtraFields: []form.ExtraField{
+		{Name: "license", Pattern: regexp.MustCompile(`^[A-Z]{2}[0-9]{4}$`)},
+		{Name: "shirt-color"},
+	}})
+	defer h.Close()
+
+	ctx := context.Background()
+	message := validMessage()
+	message.Data["license"] = "AB1234"
+	message.Data["shirt-color"] = "geel"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Extra["license"] != "AB1234" || registration.Extra["shirt-color"] != "geel" {
+		t.Errorf("expected the extra fields to be stored, got %v", registration.Extra)
+	}
+
+	for _, license := range []string{"", "1234AB"} {
+		message = clubMessage("Kinheim")
+		message.Data["license"] = license
+		message.Data["shirt-color"] = "rood"
+		if _, err = h.Handle(ctx, message); err == nil {
+			t.Errorf("expected license %q to be rejected", license)
+		}
+	}
+}
+
+// withTeams is validMessage with the teams in slots 1 to n
+func withTeams(n int) form.Message {
+	message := validMessage()
+	for i := 1; i <= n; i++ {
+		message.Data[fmt.Sprintf("team%d-name", i)] = fmt.Sprintf("Heren %d", i)
+		message.Data[fmt.Sprintf("team%d-type", i)] = "Heren"
+		message.Data[fmt.Sprintf("team%d-level", i)] = "Regio 1"
+	}
+	return message
+}
+
+func TestHandleTeamsBeyondSlots(t *testing.T) {
+	ctx := context.Background()
+
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{MaxTeams: 5})
+	result, err := h.Handle(ctx, withTeams(7))
+	h.Close()
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if result.Teams != 5 || len(result.Warnings) != 1 {
+		t.Errorf("expected 5 teams and a warning about the dropped teams, got %d and %q", result.Teams, result.Warnings)
+	}
+
+	h, _ = newHandler(t, formtest.NewMemoryStore(), form.Config{MaxTeams: 5, StrictTeamSlots: true})
+	defer h.Close()
+	if _, err = h.Handle(ctx, withTeams(7)); err == nil {
+		t.Error("expected teams beyond the slots to be rejected in strict mode")
+	}
+	if _, err = h.Handle(ctx, withTeams(5)); err != nil {
+		t.Errorf("expected the teams within the slots to be accepted, got %v", err)
+	}
+}
+
+func TestHandleSortTeams(t *testing.T) {
+	// the same teams in another order
+	first := validMessage()
+	first.Data["team1-name"], first.Data["team1-type"] = "Heren 2", "Heren"
+	first.Data["team2-name"], first.Data["team2-type"], first.Data["team2-level"] = "Dames 1", "Dames", "Regio 1"
+	first.Data["team3-name"], first.Data["team3-type"], first.Data["team3-level"] = "Heren 1", "Heren", "Regio 1"
+
+	second := validMessage()
+	second.Data["team1-name"], second.Data["team1-type"] = "Heren 1", "Heren"
+	second.Data["team2-name"], second.Data["team2-type"], second.Data["team2-level"] = "Heren 2", "Heren", "Regio 1"
+	second.Data["team3-name"], second.Data["team3-type"], second.Data["team3-level"] = "Dames 1", "Dames", "Regio 1"
+
+	ctx := context.Background()
+	for _, sorted := range []bool{false, true} {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{SortTeams: sorted})
+
+		results := make([]form.Result, 2)
+		for i, message := range []form.Message{first, second} {
+			var err error
+			if results[i], err = h.Handle(ctx, message); err != nil {
+				t.Fatalf("Handle failed: %v", err)
+			}
+		}
+		h.Close()
+
+		registration, _, err := store.Registration(ctx, results[0].SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored []string
+		for _, team := range registration.Teams {
+			stored = append(stored, fmt.Sprintf("%d:%s", team.Slot, team.Name))
+		}
+
+		expected := "1:Heren 2, 2:Dames 1, 3:Heren 1"
+		if sorted {
+			expected = "1:Dames 1, 2:Heren 1, 3:Heren 2"
+		}
+		if strings.Join(stored, ", ") != expected {
+			t.Errorf("sorted %t: expected %s, got %v", sorted, expected, stored)
+		}
+
+		// sorted, the order of the slots does not make another submission
+		if duplicate := results[0].SubscriptionID == results[1].SubscriptionID; duplicate != sorted {
+			t.Errorf("sorted %t: expected the second submission to be a duplicate only when sorted, got %s and %s",
+				sorted, results[0].SubscriptionID, results[1].SubscriptionID)
+		}
+	}
+}
+
+func TestHandleFullName(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	delete(message.Data, "contact-name")
+	delete(message.Data, "contact-surname")
+	message.Data["contact-fullname"] = "Jan van der Berg"
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.Name != "Jan" || registration.Surname != "van der Berg" {
+		t.Errorf("expected Jan and van der Berg, got %q and %q", registration.Name, registration.Surname)
+	}
+}
+
+func TestHandleAvailability(t *testing.T) {
+	days := []string{"ma", "di", "wo", "do", "vr"}
+	ctx := context.Background()
+
+	tests := []struct {
+		availability string
+		strict       bool
+		stored       string
+		warned       bool
+		rejected     bool
+	}{
+		{"ma, WO", false, "ma,wo", false, false},
+		{"", true, "", false, false},
+		{"ma,za", false, "ma", true, false},
+		{"ma,za", true, "", false, true},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{Days: days, StrictAvailability: test.strict})
+
+		message := validMessage()
+		message.Data["team1-availability"] = test.availability
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("availability %q, strict %t: expected a validation error, got %v", test.availability, test.strict, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("availability %q, strict %t: Handle failed: %v", test.availability, test.strict, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if availability := registration.Teams[0].Availability; availability != test.stored {
+			t.Errorf("availability %q, strict %t: expected %q to be stored, got %q", test.availability, test.strict, test.stored, availability)
+		}
+		if warned := len(result.Warnings) > 0; warned != test.warned {
+			t.Errorf("availability %q, strict %t: expected a warning %t, got %v", test.availability, test.strict, test.warned, result.Warnings)
+		}
+	}
+}
+
+func TestHandleContiguousTeams(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		slots      []int
+		contiguous bool
+		rejected   bool
+	}{
+		{[]int{1, 2}, true, false},
+		{[]int{1, 3}, false, false},
+		{[]int{1, 3}, true, true},
+		{[]int{2}, true, true},
+	}
+
+	for _, test := range tests {
+		h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{ContiguousTeams: test.contiguous})
+
+		message := validMessage()
+		delete(message.Data, "team1-name")
+		for _, slot := range test.slots {
+			message.Data[fmt.Sprintf("team%d-name", slot)] = fmt.Sprintf("Heren %d", slot)
+			message.Data[fmt.Sprintf("team%d-type", slot)] = "Heren"
+			message.Data[fmt.Sprintf("team%d-level", slot)] = "Regio 1"
+		}
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("slots %v, contiguous %t: expected a validation error, got %v", test.slots, test.contiguous, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("slots %v, contiguous %t: Handle failed: %v", test.slots, test.contiguous, err)
+		} else if result.Teams != len(test.slots) {
+			t.Errorf("slots %v, contiguous %t: expected %d teams, got %d", test.slots, test.contiguous, len(test.slots), result.Teams)
+		}
+	}
+}
+
+func TestHandleConsent(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		consent  string
+		rejected bool
+	}{
+		{"Ik ga akkoord", false},
+		{"1", false},
+		{"", true},
+		{"0", true},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{ConsentVersion: "2018-05"})
+
+		message := validMessage()
+		message.Data["contact-consent"] = test.consent
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("consent %q: expected a validation error, got %v", test.consent, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("consent %q: Handle failed: %v", test.consent, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.ConsentVersion != "2018-05" || !registration.ConsentTime.Equal(submitTime) {
+			t.Errorf("consent %q: expected version 2018-05 at %v, got %q at %v",
+				test.consent, submitTime, registration.ConsentVersion, registration.ConsentTime)
+		}
+	}
+}
+
+func TestHandleWithoutConsentVersion(t *testing.T) {
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	ctx := context.Background()
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("expected consent not to be required, got %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registration.ConsentVersion != "" || !registration.ConsentTime.IsZero() {
+		t.Errorf("expected no consent to be stored, got %q at %v", registration.ConsentVersion, registration.ConsentTime)
+	}
+}
+
+func TestHandleUniqueTeamNames(t *testing.T) {
+	ctx := context.Background()
+
+	for _, unique := range []bool{false, true} {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{UniqueTeamNames: unique})
+
+		message := validMessage()
+		message.Data["team1-name"] = "  Heren   1 "
+		message.Data["team2-name"] = "heren 1"
+		message.Data["team2-type"] = "Heren"
+		message.Data["team2-level"] = "Regio 2"
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if unique {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("expected the same team twice to be rejected, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("expected the same team twice to be accepted, got %v", err)
+		}
+		if teams := store.Teams(result.SubscriptionID); strings.Join(teams, ", ") != "Heren 1, heren 1" {
+			t.Errorf("expected the names to be stored normalized in their own case, got %q", teams)
+		}
+	}
+}
+
+func TestHandleLevelCodes(t *testing.T) {
+	codes := map[string]int{"Regio 1": 31, "Regio 2": 32}
+	ctx := context.Background()
+
+	tests := []struct {
+		level    string
+		strict   bool
+		code     int
+		rejected bool
+	}{
+		{"Regio 1", false, 31, false},
+		{"Regio 1", true, 31, false},
+		{"Regio 3-4", false, 0, false},
+		{"Regio 3-4", true, 0, true},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{LevelCodes: codes, StrictLevelCodes: test.strict})
+
+		message := validMessage()
+		message.Data["team1-level"] = test.level
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("level %s, strict %t: expected a validation error, got %v", test.level, test.strict, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("level %s, strict %t: Handle failed: %v", test.level, test.strict, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := registration.Teams[0].LevelCode; code != test.code {
+			t.Errorf("level %s, strict %t: expected code %d, got %d", test.level, test.strict, test.code, code)
+		}
+	}
+}
+
+func TestHandlePhoneRegion(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		region       string
+		fromLanguage bool
+		stored       string
+	}{
+		{"", false, "+31612345678"},
+		{"BE", false, "+32612345678"},
+		// the Dutch form has the Dutch region
+		{"BE", true, "+31612345678"},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{PhoneRegion: test.region, PhoneRegionFromLanguage: test.fromLanguage})
+
+		message := validMessage()
+		message.Data["contact-phone"] = "06-12345678"
+		result, err := h.Handle(ctx, message)
+		h.Close()
+		if err != nil {
+			t.Fatalf("region %q: Handle failed: %v", test.region, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.Phone != test.stored {
+			t.Errorf("region %q, from language %t: expected %s, got %s", test.region, test.fromLanguage, test.stored, registration.Phone)
+		}
+	}
+
+	if _, err := form.NewHandlerWith(formtest.NewMemoryStore(), &formtest.Clock{T: submitTime}, &formtest.IDs{}, form.Config{PhoneRegion: "XX"}); err == nil {
+		t.Error("expected an unknown phone region to be rejected")
+	}
+}
+
+func TestHandleStoresSeasonLabel(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		start  time.Month
+		season string
+	}{
+		{0, ""},
+		{time.August, "2017-2018"},
+		{time.April, "2018-2019"},
+	} {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{SeasonStartMonth: test.start})
+
+		result, err := h.Handle(ctx, validMessage())
+		h.Close()
+		if err != nil {
+			t.Fatalf("Handle failed: %v", err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if registration.Season != test.season || registration.Year != 2018 {
+			t.Errorf("start %d: expected season %q in 2018, got %q in %d", test.start, test.season, registration.Season, registration.Year)
+		}
+	}
+
+	if _, err := form.NewHandlerWith(formtest.NewMemoryStore(), &formtest.Clock{T: submitTime}, &formtest.IDs{}, form.Config{SeasonStartMonth: 13}); err == nil {
+		t.Error("expected a start month beyond December to be rejected")
+	}
+}
+
+func TestQueueRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    []error
+		retries int
+		saves   int
+		stored  bool
+	}{
+		{"succeeds on retry", []error{driver.ErrBadConn, driver.ErrBadConn}, 2, 3, true},
+		{"retries exhausted", []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn}, 2, 3, false},
+		{"retries disabled", []error{driver.ErrBadConn}, -1, 1, false},
+		{"not transient", []error{&pq.Error{Code: "23502"}}, 2, 1, false},
+	}
+
+	for _, test := range tests {
+		store := &scriptedStore{MemoryStore: formtest.NewMemoryStore(), errs: test.errs}
+		// without store retries every attempt of the queue is one save
+		h, _ := newHandler(t, store, form.Config{
+			QueueSize:    1,
+			QueueRetries: test.retries,
+			QueueBackoff: time.Millisecond,
+			StoreRetries: -1,
+		})
+
+		if _, err := h.Handle(context.Background(), validMessage()); err != nil {
+			t.Fatalf("%s: Handle failed: %v", test.name, err)
+		}
+		h.Close()
+
+		if store.saves != test.saves {
+			t.Errorf("%s: expected %d attempts, got %d", test.name, test.saves, store.saves)
+		}
+		if stored := store.Registrations() == 1; stored != test.stored {
+			t.Errorf("%s: expected stored to be %t, got %d registrations", test.name, test.stored, store.Registrations())
+		}
+		if deadLettered := len(store.DeadLetters) == 1; deadLettered == test.stored {
+			t.Errorf("%s: expected a dead letter only when not stored, got %d", test.name, len(store.DeadLetters))
+		}
+	}
+}
+
+func TestHandleMinSubmitInterval(t *testing.T) {
+	ctx := context.Background()
+	h, clock := newHandler(t, formtest.NewMemoryStore(), form.Config{MinSubmitInterval: time.Minute})
+	defer h.Close()
+
+	if _, err := h.Handle(ctx, validMessage()); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	// another submission of the same club soon after
+	clock.Advance(20 * time.Second)
+	changed := withTeams(2)
+	_, err := h.Handle(ctx, changed)
+	tooSoon, ok := err.(form.TooSoonError)
+	if !ok {
+		t.Fatalf("expected a TooSoonError within the interval, got %v", err)
+	}
+	if tooSoon.RetryAfter != 40*time.Second || tooSoon.Message == "" {
+		t.Errorf("expected to retry after 40s with a message, got %v and %q", tooSoon.RetryAfter, tooSoon.Message)
+	}
+
+	// other clubs are not held up
+	if _, err = h.Handle(ctx, clubMessage("Kinheim")); err != nil {
+		t.Errorf("expected another club to be accepted, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err = h.Handle(ctx, changed); err != nil {
+		t.Errorf("expected the submission to be accepted after the interval, got %v", err)
+	}
+}
+
+func TestHandleRegionalLevels(t *testing.T) {
+	ctx := context.Background()
+	store := formtest.NewMemoryStore()
+	h, _ := newHandler(t, store, form.Config{RegionalLevels: map[string][]string{"Heren": {"Regio 1", "Regio 2"}}})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team1-level"] = "regio midden"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if level := registration.Teams[0].Level; level != "Regio 2" {
+		t.Errorf("expected the level to be stored as Regio 2, got %q", level)
+	}
+
+	message = clubMessage("Kinheim")
+	message.Data["team1-level"] = "Regio 3"
+	if _, err = h.Handle(ctx, message); err == nil {
+		t.Fatal("expected a regional level that is not played for the type to be rejected")
+	} else if _, ok := err.(form.ValidationErrors); !ok {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestHandleMaxTeamsPerType(t *testing.T) {
+	caps := map[string]int{"Heren": 2}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		types    []string
+		rejected bool
+	}{
+		{"within the cap", []string{"Heren", "Heren", "Dames"}, false},
+		{"over the cap", []string{"Heren", "Heren", "Heren"}, true},
+		{"uncapped type", []string{"Dames", "Dames", "Dames"}, false},
+	}
+
+	for _, test := range tests {
+		h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{MaxTeamsPerType: caps})
+
+		message := withTeams(len(test.types))
+		for i, teamType := range test.types {
+			message.Data[fmt.Sprintf("team%d-type", i+1)] = teamType
+		}
+		_, err := h.Handle(ctx, message)
+		h.Close()
+
+		if !test.rejected {
+			if err != nil {
+				t.Errorf("%s: expected the submission to be accepted, got %v", test.name, err)
+			}
+			continue
+		}
+
+		problems, ok := err.(form.ValidationErrors)
+		if !ok {
+			t.Fatalf("%s: expected a validation error, got %v", test.name, err)
+		}
+		if !strings.Contains(problems[0], "Heren") || !strings.Contains(problems[0], "2") {
+			t.Errorf("%s: expected the error to name the type and its cap, got %q", test.name, problems[0])
+		}
+	}
+}
+
+func TestAddTeamMaxTeamsPerType(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{MaxTeamsPerType: map[string]int{"Heren": 2}})
+	defer h.Close()
+
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	// the English type is capped by its translation
+	team := form.TeamRequest{Name: "Heren 2", Type: "Men", Level: "National", Language: "EN"}
+	if _, err = h.AddTeam(ctx, result.SubscriptionID, team); err != nil {
+		t.Fatalf("expected the second men's team to be added, got %v", err)
+	}
+
+	team.Name = "Heren 3"
+	_, err = h.AddTeam(ctx, result.SubscriptionID, team)
+	if problems, ok := err.(form.ValidationErrors); !ok || !strings.Contains(problems[0], "Heren") {
+		t.Errorf("expected a validation error naming Heren, got %v", err)
+	}
+
+	if _, err = h.AddTeam(ctx, result.SubscriptionID, form.TeamRequest{Name: "Dames 1", Type: "Dames", Level: "Regio 1"}); err != nil {
+		t.Errorf("expected a team of another type to be added, got %v", err)
+	}
+}
+
+func TestAddTeamValidatesLikeFormTeams(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{
+		Poules:       map[string][]string{"Heren": {"A", "B"}},
+		StrictPoules: true,
+	})
+	defer h.Close()
+
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	for _, team := range []form.TeamRequest{
+		{Name: "Heren 2", Type: "Heren", Level: "Regio 1", Poule: "Z"},
+		{Name: "Heren 2"},
+		{Type: "Heren", Level: "Regio 1"},
+	} {
+		if _, err = h.AddTeam(ctx, result.SubscriptionID, team); err == nil {
+			t.Errorf("%+v: expected the team to be rejected", team)
+		} else if _, ok := err.(form.ValidationErrors); !ok {
+			t.Errorf("%+v: expected a validation error, got %v", team, err)
+		}
+	}
+}
+
+func TestHandleSecondContact(t *testing.T) {
+	ctx := context.Background()
+	complete := map[string]string{
+		"contact2-name":    "Piet",
+		"contact2-surname": "de Vries",
+		"contact2-email":   "piet@example.com",
+		"contact2-phone":   "06 87654321",
+	}
+
+	tests := []struct {
+		name     string
+		fields   []string
+		email    string
+		rejected bool
+	}{
+		{"no second contact", nil, "", false},
+		{"complete", []string{"contact2-name", "contact2-surname", "contact2-email", "contact2-phone"}, "", false},
+		{"partial", []string{"contact2-name", "contact2-email"}, "", true},
+		{"invalid email", []string{"contact2-name", "contact2-surname", "contact2-email", "contact2-phone"}, "piet", true},
+	}
+
+	for _, test := range tests {
+		store := formtest.NewMemoryStore()
+		h, _ := newHandler(t, store, form.Config{})
+
+		message := validMessage()
+		for _, field := range test.fields {
+			message.Data[field] = complete[field]
+		}
+		if test.email != "" {
+			message.Data["contact2-email"] = test.email
+		}
+		result, err := h.Handle(ctx, message)
+		h.Close()
+
+		if test.rejected {
+			if _, ok := err.(form.ValidationErrors); !ok {
+				t.Errorf("%s: expected a validation error, got %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: Handle failed: %v", test.name, err)
+		}
+
+		registration, _, err := store.Registration(ctx, result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second := registration.SecondContact
+		if test.fields == nil {
+			if second != nil {
+				t.Errorf("%s: expected no second contact, got %+v", test.name, second)
+			}
+			continue
+		}
+		expected := form.Contact{Name: "Piet", Surname: "de Vries", Email: "piet@example.com", Phone: "+31687654321"}
+		if second == nil || *second != expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, expected, second)
+		}
+	}
+}
+
+func TestConfirmationShowsSecondContact(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["contact2-name"] = "Piet"
+	message.Data["contact2-surname"] = "de Vries"
+	message.Data["contact2-email"] = "piet@example.com"
+	message.Data["contact2-phone"] = "06 87654321"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err = h.Confirmation(ctx, result.SubscriptionID, &page); err != nil {
+		t.Fatalf("Confirmation failed: %v", err)
+	}
+	if !strings.Contains(page.String(), "Piet de Vries") || !strings.Contains(page.String(), "piet@example.com") {
+		t.Errorf("expected the second contact on the confirmation, got %s", page.String())
+	}
+}
+
+func TestConfirmationShowsAvailability(t *testing.T) {
+	ctx := context.Background()
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{Days: []string{"ma", "di", "wo"}})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team1-availability"] = "di,wo"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	var page bytes.Buffer
+	if err = h.Confirmation(ctx, result.SubscriptionID, &page); err != nil {
+		t.Fatalf("Confirmation failed: %v", err)
+	}
+	if !strings.Contains(page.String(), "<td>di,wo</td>") {
+		t.Errorf("expected the availability on the confirmation, got %s", page.String())
+	}
+}
+
+func TestHandleLogsThroughTheConfiguredLogger(t *testing.T) {
+	logger, logs := formtest.NewLogger()
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{Logger: logger})
+	defer h.Close()
+
+	message := validMessage()
+	delete(message.Data, "contact-email")
+	if _, err := h.Handle(context.Background(), message); err == nil {
+		t.Fatal("expected the submission without email to be rejected")
+	}
+
+	messages := logs.Messages()
+	if len(messages) == 0 || messages[0] != "Handling form" {
+		t.Errorf("expected the entries of the handler on the configured logger, got %v", messages)
+	}
+}
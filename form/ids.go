@@ -0,0 +1,114 @@
+package form
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// IDGenerator creates subscription IDs
+type IDGenerator interface {
+	// NewID returns an ID that does not occur in existing
+	NewID(existing map[string]struct{}) string
+}
+
+type randomIDs struct {
+	rng    *rand.Rand
+	prefix string
+	width  int
+	// the random number is drawn from [min, max)
+	min int
+	max int
+}
+
+// NewRandomIDs creates an IDGenerator for random zero-padded IDs of width digits, such as "012345"
+func NewRandomIDs(width int) IDGenerator {
+	return newRandomIDs("", width, false)
+}
+
+// NewNumericRandomIDs creates an IDGenerator for random IDs of width digits that never start with a
+// zero, such as "112345", so they survive systems that store them as numbers
+func NewNumericRandomIDs(width int) IDGenerator {
+	return newRandomIDs("", width, true)
+}
+
+// NewPrefixedRandomIDs creates an IDGenerator for random zero-padded IDs of width digits after
+// prefix, such as "9012345" for prefix "9"
+func NewPrefixedRandomIDs(prefix string, width int) IDGenerator {
+	return newRandomIDs(prefix, width, false)
+}
+
+func newRandomIDs(prefix string, width int, noLeadingZero bool) *randomIDs {
+	max := 1
+	for i := 0; i < width; i++ {
+		max *= 10
+	}
+
+	min := 0
+	if noLeadingZero {
+		min = max / 10
+	}
+
+	return &randomIDs{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		prefix: prefix,
+		width:  width,
+		min:    min,
+		max:    max,
+	}
+}
+
+func (r *randomIDs) NewID(existing map[string]struct{}) string {
+	for {
+		newID := fmt.Sprintf("%s%0*d", r.prefix, r.width, r.min+r.rng.Intn(r.max-r.min))
+		if _, exists := existing[newID]; !exists {
+			return newID
+		}
+	}
+}
+
+type sequentialIDs struct {
+	year int
+}
+
+// NewSequentialIDs creates an IDGenerator for sequential IDs prefixed with the year, such as "24-0123"
+func NewSequentialIDs(year int) IDGenerator {
+	return &sequentialIDs{year}
+}
+
+func (s *sequentialIDs) NewID(existing map[string]struct{}) string {
+	prefix := fmt.Sprintf("%02d-", s.year%100)
+
+	// continue after the highest ID of this year, gaps left by deleted registrations are not reused
+	next := 1
+	for id := range existing {
+		var n int
+		if strings.HasPrefix(id, prefix) {
+			if _, err := fmt.Sscanf(id[len(prefix):], "%d", &n); err == nil && n >= next {
+				next = n + 1
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, next)
+}
+
+// newDecoyIDs creates the IDGenerator for the results of spam, which are never stored. Sequential
+// IDs are predictable, so the next ID would be handed to the bot and then to a club. Decoys get a
+// random number of the season from 5000 instead, which a season does not reach. Random IDs are
+// already unpredictable and decoys use them as they are.
+func newDecoyIDs(ids IDGenerator) IDGenerator {
+	sequential, ok := ids.(*sequentialIDs)
+	if !ok {
+		return ids
+	}
+
+	return &randomIDs{
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		prefix: fmt.Sprintf("%02d-", sequential.year%100),
+		width:  4,
+		min:    5000,
+		max:    10000,
+	}
+}
@@ -0,0 +1,129 @@
+package form_test
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+func TestRandomIDsAreUnique(t *testing.T) {
+	ids := form.NewRandomIDs(1)
+	existing := make(map[string]struct{})
+	for i := 0; i < 9; i++ {
+		existing[ids.NewID(existing)] = struct{}{}
+	}
+
+	// a single ID of one digit is left, so it has to be drawn
+	last := ids.NewID(existing)
+	if _, exists := existing[last]; exists || len(last) != 1 {
+		t.Errorf("expected the one remaining ID, got %q", last)
+	}
+}
+
+func TestSequentialIDs(t *testing.T) {
+	ids := form.NewSequentialIDs(2024)
+	existing := map[string]struct{}{}
+
+	tests := []struct {
+		existing string
+		expected string
+	}{
+		{"", "24-0001"},
+		{"24-0001", "24-0002"},
+		// IDs of other years and other strategies do not count
+		{"23-0417", "24-0002"},
+		{"123456", "24-0002"},
+		{"24-0041", "24-0042"},
+	}
+
+	for _, test := range tests {
+		if test.existing != "" {
+			existing[test.existing] = struct{}{}
+		}
+		if id := ids.NewID(existing); id != test.expected {
+			t.Errorf("after %q expected %q, got %q", test.existing, test.expected, id)
+		}
+	}
+}
+
+func TestRandomIDWidth(t *testing.T) {
+	for _, width := range []int{4, 6, 8} {
+		digits := regexp.MustCompile("^[0-9]{" + strconv.Itoa(width) + "}$")
+		generators := map[string]form.IDGenerator{
+			"padded":  form.NewRandomIDs(width),
+			"numeric": form.NewNumericRandomIDs(width),
+		}
+
+		for name, ids := range generators {
+			for i := 0; i < 1000; i++ {
+				id := ids.NewID(nil)
+				if !digits.MatchString(id) {
+					t.Fatalf("%s: expected %d digits, got %q", name, width, id)
+				}
+				if name == "numeric" && id[0] == '0' {
+					t.Fatalf("%s: expected no leading zero, got %q", name, id)
+				}
+			}
+		}
+	}
+}
+
+func TestNumericRandomIDsAreUnique(t *testing.T) {
+	ids := form.NewNumericRandomIDs(1)
+	existing := make(map[string]struct{})
+	for i := 0; i < 9; i++ {
+		id := ids.NewID(existing)
+		if _, exists := existing[id]; exists || id == "0" {
+			t.Fatalf("expected a new ID without leading zero, got %q after %d IDs", id, i)
+		}
+		existing[id] = struct{}{}
+	}
+}
+
+func TestPrefixedRandomIDs(t *testing.T) {
+	ids := form.NewPrefixedRandomIDs("9", 6)
+	prefixed := regexp.MustCompile("^9[0-9]{6}$")
+	for i := 0; i < 1000; i++ {
+		if id := ids.NewID(nil); !prefixed.MatchString(id) {
+			t.Fatalf("expected 9 followed by 6 digits, got %q", id)
+		}
+	}
+}
+
+func TestHandlerIDOptions(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		config  form.Config
+		pattern string
+	}{
+		{form.Config{IDNoLeadingZero: true}, "^[1-9][0-9]{5}$"},
+		{form.Config{IDPrefix: "7", IDWidth: 4}, "^7[0-9]{4}$"},
+	}
+
+	for _, test := range tests {
+		h, err := form.NewHandler(formtest.NewMemoryStore(), test.config)
+		if err != nil {
+			t.Fatalf("%+v: NewHandler failed: %v", test.config, err)
+		}
+
+		result, err := h.Handle(ctx, validMessage())
+		h.Close()
+		if err != nil {
+			t.Fatalf("%+v: Handle failed: %v", test.config, err)
+		}
+		if !regexp.MustCompile(test.pattern).MatchString(result.SubscriptionID) {
+			t.Errorf("%+v: expected an ID matching %s, got %q", test.config, test.pattern, result.SubscriptionID)
+		}
+	}
+
+	for _, config := range []form.Config{{IDPrefix: "07"}, {IDPrefix: "12345", IDWidth: 6}} {
+		if _, err := form.NewHandler(formtest.NewMemoryStore(), config); err == nil {
+			t.Errorf("%+v: expected the prefix to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package form
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Language is the language of the form a registration was submitted with
+type Language string
+
+const (
+	nl = Language("NL")
+	en = Language("EN")
+)
+
+// languages describes every supported language, adding a language starts here
+var languages = map[Language]struct {
+	// title of the wordpress form in this language
+	title string
+	// code stored in the taal column
+	code string
+	// region of the phone numbers on this form with PhoneRegionFromLanguage, empty for the default
+	region string
+}{
+	nl: {"Inschrijven teams", "NL", "NL"},
+	en: {"Sign up teams", "EN", ""},
+}
+
+// languageOf returns the language of the form with the given title, ignoring case and surrounding
+// whitespace unless exact
+func languageOf(title string, exact bool) (Language, bool) {
+	for lang, info := range languages {
+		if info.title == title || !exact && strings.EqualFold(info.title, strings.TrimSpace(title)) {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+// languageOfCode returns the language stored as code, defaulting to Dutch for unknown codes
+func languageOfCode(code string) Language {
+	for lang, info := range languages {
+		if info.code == code {
+			return lang
+		}
+	}
+
+	return nl
+}
+
+// Code returns the code stored for the language
+func (l Language) Code() string {
+	return languages[l].code
+}
+
+// checkLanguages verifies that every language can be stored and has all texts
+func checkLanguages() error {
+	for lang, info := range languages {
+		if info.code == "" || len(info.code) > 2 {
+			return fmt.Errorf("Language %s has no valid storage code", lang)
+		}
+
+		for code := range catalog[en] {
+			if _, ok := catalog[lang][code]; !ok {
+				return fmt.Errorf("Language %s has no text for %s", lang, code)
+			}
+		}
+	}
+
+	return nil
+}
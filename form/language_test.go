@@ -0,0 +1,66 @@
+package form
+
+import "testing"
+
+func TestLanguagesHaveStorageCodes(t *testing.T) {
+	if err := checkLanguages(); err != nil {
+		t.Fatal(err)
+	}
+
+	codes := make(map[string]Language)
+	for _, lang := range []Language{nl, en} {
+		code := lang.Code()
+		if code == "" {
+			t.Errorf("expected a storage code for %s", lang)
+			continue
+		}
+		if other, taken := codes[code]; taken {
+			t.Errorf("expected %s and %s to have different codes, both have %s", other, lang, code)
+		}
+		codes[code] = lang
+
+		if stored := languageOfCode(code); stored != lang {
+			t.Errorf("expected %s to be read back as %s, got %s", code, lang, stored)
+		}
+	}
+
+	if len(codes) != len(languages) {
+		t.Errorf("expected a test for each of the %d languages", len(languages))
+	}
+}
+
+func TestCheckLanguagesRejectsMissingCode(t *testing.T) {
+	info := languages[en]
+	defer func() { languages[en] = info }()
+
+	missing := info
+	missing.code = ""
+	languages[en] = missing
+
+	if err := checkLanguages(); err == nil {
+		t.Error("expected a language without storage code to be rejected")
+	}
+}
+
+func TestLanguageOf(t *testing.T) {
+	tests := []struct {
+		title string
+		exact bool
+		lang  Language
+		ok    bool
+	}{
+		{"Inschrijven teams", false, nl, true},
+		{"Inschrijven teams", true, nl, true},
+		{"inschrijven Teams", false, nl, true},
+		{"inschrijven Teams", true, "", false},
+		{"  Inschrijven teams\n", false, nl, true},
+		{"  Inschrijven teams\n", true, "", false},
+		{"Inschrijven vrijwilligers", false, "", false},
+	}
+
+	for _, test := range tests {
+		if lang, ok := languageOf(test.title, test.exact); lang != test.lang || ok != test.ok {
+			t.Errorf("%q, exact %t: expected %q %t, got %q %t", test.title, test.exact, test.lang, test.ok, lang, ok)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package form
+
+import (
+	"strconv"
+	"strings"
+)
+
+// regionalTiers maps the numbered regional levels to the Dutch level that contains them, the
+// lowest regional levels are played together
+var regionalTiers = map[int]string{
+	1: "Regio 1",
+	2: "Regio 2",
+	3: "Regio 3-4",
+	4: "Regio 3-4",
+}
+
+// regionalQualifiers maps the named regional levels, in English and Dutch, to their number
+var regionalQualifiers = map[string]int{
+	"high":   1,
+	"hoog":   1,
+	"middle": 2,
+	"mid":    2,
+	"midden": 2,
+	"low":    3,
+	"laag":   3,
+}
+
+// regionalLevel normalizes the variants of a regional level, such as "Regional Low", "Regio 3",
+// "regio 3 - 4" and "Regional 3/4", to the Dutch level. A range is only accepted when it lies
+// within a single level, "Regio 2-3" is not.
+func regionalLevel(level string) (string, bool) {
+	rest := strings.ToLower(strings.TrimSpace(level))
+	switch {
+	case strings.HasPrefix(rest, "regional"):
+		rest = rest[len("regional"):]
+	case strings.HasPrefix(rest, "regio"):
+		rest = rest[len("regio"):]
+	default:
+		return "", false
+	}
+
+	bounds := strings.FieldsFunc(rest, func(r rune) bool {
+		return r == '-' || r == '/' || r == ' '
+	})
+	if len(bounds) == 0 || len(bounds) > 2 {
+		return "", false
+	}
+
+	var dutch string
+	for _, bound := range bounds {
+		number, ok := regionalQualifiers[bound]
+		if !ok {
+			var err error
+			if number, err = strconv.Atoi(bound); err != nil {
+				return "", false
+			}
+		}
+
+		tier, ok := regionalTiers[number]
+		if !ok || (dutch != "" && tier != dutch) {
+			return "", false
+		}
+		dutch = tier
+	}
+
+	return dutch, true
+}
+
+// translateLevel converts the level of a team to its Dutch equivalent, regional variants are
+// normalized on both forms, other English levels are translated
+func translateLevel(level string, language Language, patterns []ValuePattern, unknown string) string {
+	if dutch, ok := regionalLevel(level); ok {
+		return dutch
+	}
+
+	if language != en {
+		return level
+	}
+
+	return translate("level", enLevels, patterns, level, unknown)
+}
+
+// regionOffered reports whether a regional level is played for teamType, a type without
+// configured regional levels accepts any level
+func regionOffered(regions map[string][]string, teamType string, level string) bool {
+	offered, ok := regions[teamType]
+	if !ok || !strings.HasPrefix(level, "Regio ") {
+		return true
+	}
+
+	for _, region := range offered {
+		if region == level {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,115 @@
+package form
+
+import (
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestTranslateEnglishLevels(t *testing.T) {
+	tests := []struct {
+		level string
+		dutch string
+	}{
+		{"National", "Bond 2"},
+		{"Regional High", "Regio 1"},
+		{"Regional Middle", "Regio 2"},
+		{"Regional Low", "Regio 3-4"},
+		{"Recreational", "Recreatief"},
+		{"Professional", defaultUnknown},
+	}
+
+	for _, test := range tests {
+		if dutch := translateLevel(test.level, en, nil, defaultUnknown); dutch != test.dutch {
+			t.Errorf("expected %q to translate to %q, got %q", test.level, test.dutch, dutch)
+		}
+	}
+}
+
+func TestRegionalLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		dutch string
+		ok    bool
+	}{
+		{"Regio 1", "Regio 1", true},
+		{"Regional 2", "Regio 2", true},
+		{"regio midden", "Regio 2", true},
+		{"Regional Mid", "Regio 2", true},
+		{"Regio 3", "Regio 3-4", true},
+		{"Regio 4", "Regio 3-4", true},
+		{"regio 3 - 4", "Regio 3-4", true},
+		{"Regional 3/4", "Regio 3-4", true},
+		{"Regio laag", "Regio 3-4", true},
+		{"Regio 2-3", "", false},
+		{"Regio 5", "", false},
+		{"Regio", "", false},
+		{"Regio 1-2-3", "", false},
+		{"Bond 2", "", false},
+	}
+
+	for _, test := range tests {
+		if dutch, ok := regionalLevel(test.level); dutch != test.dutch || ok != test.ok {
+			t.Errorf("%q: expected %q %t, got %q %t", test.level, test.dutch, test.ok, dutch, ok)
+		}
+	}
+}
+
+func TestRegionOffered(t *testing.T) {
+	regions := map[string][]string{"Heren": {"Regio 1", "Regio 2"}}
+
+	tests := []struct {
+		teamType string
+		level    string
+		offered  bool
+	}{
+		{"Heren", "Regio 1", true},
+		{"Heren", "Regio 3-4", false},
+		{"Heren", "Bond 2", true},
+		{"Dames", "Regio 3-4", true},
+	}
+
+	for _, test := range tests {
+		if offered := regionOffered(regions, test.teamType, test.level); offered != test.offered {
+			t.Errorf("%s %s: expected offered %t, got %t", test.teamType, test.level, test.offered, offered)
+		}
+	}
+}
+
+func TestTranslateLogsUnknownValue(t *testing.T) {
+	hook := &warnings{}
+	log.AddHook(hook)
+
+	if dutch := translateLevel("Regional Medium", en, nil, defaultUnknown); dutch != defaultUnknown {
+		t.Fatalf("expected Regional Medium to be unknown, got %q", dutch)
+	}
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+
+	for _, entry := range hook.entries {
+		if entry.Data["value"] == "Regional Medium" && entry.Data["field"] == "level" {
+			return
+		}
+	}
+	t.Errorf("expected a warning with the original value, got %v", hook.entries)
+}
+
+// warnings records the logged warnings
+type warnings struct {
+	mu      sync.Mutex
+	entries []*log.Entry
+}
+
+func (w *warnings) Levels() []log.Level {
+	return []log.Level{log.WarnLevel}
+}
+
+func (w *warnings) Fire(entry *log.Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.entries = append(w.entries, entry)
+	return nil
+}
@@ -0,0 +1,54 @@
+package form
+
+import (
+	"strings"
+	"sync"
+)
+
+// keyedLock serializes work per key while letting different keys proceed in parallel
+type keyedLock struct {
+	mu    sync.Mutex
+	locks map[string]*refLock
+}
+
+type refLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedLock() *keyedLock {
+	return &keyedLock{locks: make(map[string]*refLock)}
+}
+
+// Lock acquires the lock for key and returns the function that releases it
+func (k *keyedLock) Lock(key string) (unlock func()) {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &refLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// clubKey identifies the submitter of a form, ignoring case and surrounding whitespace
+func clubKey(form Registration) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+
+	return normalize(form.Club) + "|" + normalize(form.Email)
+}
@@ -0,0 +1,115 @@
+package form
+
+import "fmt"
+
+// message codes of the texts returned to the club
+const (
+	msgConfirmation     = "confirmation"
+	msgEmptySubmission  = "emptySubmission"
+	msgMissingValue     = "missingValue"
+	msgInvalidEmail     = "invalidEmail"
+	msgEmailDomain      = "emailDomain"
+	msgWrongSeason      = "wrongSeason"
+	msgTeamIncomplete   = "teamIncomplete"
+	msgNoTeams          = "noTeams"
+	msgInvalidPoule     = "invalidPoule"
+	msgUnknownValue     = "unknownValue"
+	msgMissingType      = "missingType"
+	msgBlocked          = "blocked"
+	msgInvalidField     = "invalidField"
+	msgTooManyTeams     = "tooManyTeams"
+	msgInvalidDays      = "invalidDays"
+	msgTeamGap          = "teamGap"
+	msgMissingConsent   = "missingConsent"
+	msgDuplicateTeam    = "duplicateTeam"
+	msgTooSoon          = "tooSoon"
+	msgRegionNotOffered = "regionNotOffered"
+	msgTooManyOfType    = "tooManyOfType"
+	msgUnusualPhone     = "unusualPhone"
+	msgTeamsDropped     = "teamsDropped"
+	labelConfirmation   = "labelConfirmation"
+	labelContact        = "labelContact"
+	labelClub           = "labelClub"
+	labelSubmitted      = "labelSubmitted"
+	labelTeams          = "labelTeams"
+	labelNotes          = "labelNotes"
+	labelSecondContact  = "labelSecondContact"
+)
+
+// catalog holds the texts returned to the club per language, as fmt templates
+var catalog = map[Language]map[string]string{
+	nl: {
+		msgConfirmation:     "Bedankt! Je inschrijfnummer is %s met %d teams.",
+		msgEmptySubmission:  "De inschrijving is leeg",
+		msgMissingValue:     "Verplicht veld ontbreekt: %s",
+		msgInvalidEmail:     "Ongeldig e-mailadres: %s",
+		msgEmailDomain:      "E-mailadres %s hoort niet bij een aangesloten vereniging",
+		msgWrongSeason:      "Inschrijving in %d hoort niet bij seizoen %d",
+		msgTeamIncomplete:   "Team %d heeft geen type en geen niveau",
+		msgNoTeams:          "De inschrijving bevat geen teams",
+		msgInvalidPoule:     "Onbekende poule %s voor team %d",
+		msgUnknownValue:     "Onbekende waarde %s voor team %d",
+		msgMissingType:      "Schrijf minstens één team in van het type %s",
+		msgBlocked:          "De inschrijving kan niet worden verwerkt",
+		msgInvalidField:     "Ongeldige waarde %s voor %s",
+		msgTooManyTeams:     "Schrijf maximaal %d teams in",
+		msgInvalidDays:      "Onbekende dagen %s voor team %d",
+		msgTeamGap:          "Team %d ontbreekt, vul de teams op volgorde in",
+		msgMissingConsent:   "Geef toestemming voor het verwerken van de gegevens",
+		msgDuplicateTeam:    "Team %s is meerdere keren ingeschreven",
+		msgTooSoon:          "Je hebt net al een inschrijving verstuurd, probeer het later opnieuw",
+		msgRegionNotOffered: "Niveau %s wordt niet gespeeld voor %s",
+		msgTooManyOfType:    "Schrijf maximaal %d teams in van het type %s",
+		msgUnusualPhone:     "Controleer het telefoonnummer %s",
+		msgTeamsDropped:     "Alleen de eerste %d teams zijn ingeschreven",
+		labelConfirmation:   "Bevestiging inschrijving %s",
+		labelContact:        "Contactpersoon",
+		labelClub:           "Vereniging",
+		labelSubmitted:      "Ingeschreven op",
+		labelTeams:          "Teams",
+		labelNotes:          "Opmerkingen",
+		labelSecondContact:  "Tweede contactpersoon",
+	},
+	en: {
+		msgConfirmation:     "Thanks! Your registration number is %s with %d teams.",
+		msgEmptySubmission:  "Empty submission",
+		msgMissingValue:     "Missing required value: %s",
+		msgInvalidEmail:     "Invalid email address: %s",
+		msgEmailDomain:      "Email address %s does not belong to a member club",
+		msgWrongSeason:      "Submission in %d does not belong to season %d",
+		msgTeamIncomplete:   "Team %d has neither type nor level",
+		msgNoTeams:          "Subscription contains no teams",
+		msgInvalidPoule:     "Unknown poule %s for team %d",
+		msgUnknownValue:     "Unknown value %s for team %d",
+		msgMissingType:      "Register at least one team of type %s",
+		msgBlocked:          "The submission cannot be accepted",
+		msgInvalidField:     "Invalid value %s for %s",
+		msgTooManyTeams:     "Register at most %d teams",
+		msgInvalidDays:      "Unknown days %s for team %d",
+		msgTeamGap:          "Team %d is missing, fill in the teams in order",
+		msgMissingConsent:   "Please consent to the processing of your data",
+		msgDuplicateTeam:    "Team %s is registered more than once",
+		msgTooSoon:          "You have just submitted a registration, please try again later",
+		msgRegionNotOffered: "Level %s is not played for %s",
+		msgTooManyOfType:    "Register at most %d teams of type %s",
+		msgUnusualPhone:     "Please check the phone number %s",
+		msgTeamsDropped:     "Only the first %d teams have been registered",
+		labelConfirmation:   "Confirmation of registration %s",
+		labelContact:        "Contact",
+		labelClub:           "Club",
+		labelSubmitted:      "Registered on",
+		labelTeams:          "Teams",
+		labelNotes:          "Notes",
+		labelSecondContact:  "Second contact",
+	},
+}
+
+// localize formats the text with the given code in language, falling back to English
+func localize(language Language, code string, args ...interface{}) string {
+	template, ok := catalog[language][code]
+	if !ok {
+		template = catalog[en][code]
+	}
+
+	return fmt.Sprintf(template, args...)
+}
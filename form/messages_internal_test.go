@@ -0,0 +1,15 @@
+package form
+
+import "testing"
+
+func TestCatalogIsComplete(t *testing.T) {
+	for language, texts := range catalog {
+		for other, otherTexts := range catalog {
+			for code := range otherTexts {
+				if _, ok := texts[code]; !ok {
+					t.Errorf("%s has no text for %s, which %s has", language.Code(), code, other.Code())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package form_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+func TestConfirmationMessage(t *testing.T) {
+	english := validMessage()
+	english.Title = "Sign up teams"
+	english.Data["team1-type"] = "Men"
+	english.Data["team1-level"] = "National"
+
+	tests := []struct {
+		name    string
+		message form.Message
+		text    string
+	}{
+		{"NL", validMessage(), "Bedankt! Je inschrijfnummer is 000001 met 2 teams."},
+		{"EN", english, "Thanks! Your registration number is 000001 with 2 teams."},
+	}
+
+	for _, test := range tests {
+		h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+
+		test.message.Data["team2-name"] = "Heren 2"
+		test.message.Data["team2-type"] = test.message.Data["team1-type"]
+		test.message.Data["team2-level"] = test.message.Data["team1-level"]
+
+		result, err := h.Handle(context.Background(), test.message)
+		h.Close()
+		if err != nil {
+			t.Fatalf("%s: Handle failed: %v", test.name, err)
+		}
+		if result.Message != test.text {
+			t.Errorf("%s: expected %q, got %q", test.name, test.text, result.Message)
+		}
+	}
+}
+
+func TestValidationMessages(t *testing.T) {
+	english := func(change func(data map[string]string)) form.Message {
+		message := validMessage()
+		message.Title = "Sign up teams"
+		message.Data["team1-type"] = "Men"
+		message.Data["team1-level"] = "National"
+		change(message.Data)
+		return message
+	}
+	dutch := func(change func(data map[string]string)) form.Message {
+		message := validMessage()
+		change(message.Data)
+		return message
+	}
+
+	missingName := func(data map[string]string) { delete(data, "contact-name") }
+	invalidEmail := func(data map[string]string) { data["contact-email"] = "jan" }
+	incompleteTeam := func(data map[string]string) { data["team1-type"], data["team1-level"] = "", "" }
+	noTeams := func(data map[string]string) { delete(data, "team1-name") }
+
+	tests := []struct {
+		message form.Message
+		text    string
+	}{
+		{dutch(missingName), "Verplicht veld ontbreekt: contact-name"},
+		{english(missingName), "Missing required value: contact-name"},
+		{dutch(invalidEmail), "Ongeldig e-mailadres: jan"},
+		{english(invalidEmail), "Invalid email address: jan"},
+		{dutch(incompleteTeam), "Team 1 heeft geen type en geen niveau"},
+		{english(incompleteTeam), "Team 1 has neither type nor level"},
+		{dutch(noTeams), "De inschrijving bevat geen teams"},
+		{english(noTeams), "Subscription contains no teams"},
+	}
+
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{})
+	defer h.Close()
+
+	for _, test := range tests {
+		_, err := h.Handle(context.Background(), test.message)
+		problems, ok := err.(form.ValidationErrors)
+		if !ok || len(problems) == 0 || problems[0] != test.text {
+			t.Errorf("expected %q, got %v", test.text, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// migrations create and update the Postgres schema, append new migrations and never change applied ones.
+// They are written to also apply cleanly to databases created before migrations existed.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS inschrijving (
+		id              SERIAL PRIMARY KEY,
+		inschrijfnummer VARCHAR(6) NOT NULL,
+		jaar            INTEGER NOT NULL,
+		voornaam        VARCHAR(20) NOT NULL,
+		achternaam      VARCHAR(30) NOT NULL,
+		email           VARCHAR(50) NOT NULL,
+		telefoon        VARCHAR(20) NOT NULL,
+		vereniging      VARCHAR(50) NOT NULL,
+		taal            VARCHAR(2) NOT NULL,
+		inschrijfdatum  TIMESTAMP NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS team (
+		id              SERIAL PRIMARY KEY,
+		inschrijvingsid INTEGER NOT NULL REFERENCES inschrijving (id),
+		teamnaam        VARCHAR(40) NOT NULL,
+		"type"          VARCHAR(40) NOT NULL,
+		niveau          VARCHAR(40) NOT NULL
+	)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS opmerkingen VARCHAR(500)`,
+	`CREATE TABLE IF NOT EXISTS dead_letters (
+		id         SERIAL PRIMARY KEY,
+		title      TEXT NOT NULL,
+		data       TEXT NOT NULL,
+		error      TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`,
+	`ALTER TABLE inschrijving ALTER COLUMN inschrijfnummer TYPE VARCHAR(10);
+	CREATE UNIQUE INDEX IF NOT EXISTS inschrijfnummer_uniek ON inschrijving (inschrijfnummer)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT now();
+	ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT now()`,
+	`ALTER TABLE team ADD COLUMN IF NOT EXISTS volgorde INTEGER`,
+	`ALTER TABLE team ADD COLUMN IF NOT EXISTS poule VARCHAR(40)`,
+	`ALTER TABLE team ADD COLUMN IF NOT EXISTS origineel_type VARCHAR(40);
+	ALTER TABLE team ADD COLUMN IF NOT EXISTS origineel_niveau VARCHAR(40)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS extra JSONB`,
+	`ALTER TABLE team ADD COLUMN IF NOT EXISTS beschikbaarheid VARCHAR(40)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS toestemming_versie VARCHAR(20);
+	ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS toestemming_op TIMESTAMP`,
+	`ALTER TABLE team ADD COLUMN IF NOT EXISTS niveau_code INTEGER`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS seizoen VARCHAR(9)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS bron VARCHAR(255)`,
+	`ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS tweede_voornaam VARCHAR(20);
+	ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS tweede_achternaam VARCHAR(30);
+	ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS tweede_email VARCHAR(50);
+	ALTER TABLE inschrijving ADD COLUMN IF NOT EXISTS tweede_telefoon VARCHAR(20)`,
+}
+
+// Migrate brings the schema up to date, recording the applied migrations in schema_migrations
+func Migrate(ctx context.Context, db *sql.DB) (err error) {
+	if _, err = db.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT now())",
+	); err != nil {
+		return
+	}
+
+	var current int
+	if err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		if err = migrate(ctx, db, version); err != nil {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"error":   err,
+				"version": version,
+			})).Error("Failed to apply migration")
+			return
+		}
+
+		log.WithField("version", version).Info("Applied migration")
+	}
+
+	return
+}
+
+func migrate(ctx context.Context, db *sql.DB, version int) (err error) {
+	var tx *sql.Tx
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, migrations[version-1]); err != nil {
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+
+	return
+}
@@ -0,0 +1,63 @@
+package form
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// callingCodes are the country calling codes of the supported phone regions
+var callingCodes = map[string]string{
+	"NL": "31",
+	"BE": "32",
+	"DE": "49",
+	"FR": "33",
+	"GB": "44",
+	"LU": "352",
+}
+
+// normalizePhone converts a phone number to E.164, such as +31612345678, reading national numbers
+// as numbers of region. Numbers that cannot be interpreted are returned unchanged and not ok.
+func normalizePhone(phone string, region string) (string, bool) {
+	digits := make([]byte, 0, len(phone))
+	for i := 0; i < len(phone); i++ {
+		switch c := phone[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '+' && len(digits) == 0:
+			digits = append(digits, c)
+		case strings.IndexByte(" -./()", c) >= 0:
+		default:
+			log.WithField("phone", phone).Warn("Cannot normalize phone number")
+			return phone, false
+		}
+	}
+
+	number := string(digits)
+	switch {
+	case strings.HasPrefix(number, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = "+" + number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = "+" + callingCodes[region] + number[1:]
+	default:
+		log.WithField("phone", phone).Warn("Cannot normalize phone number")
+		return phone, false
+	}
+
+	// the national trunk 0 is often written after the country code, as in +31 (0)6 12345678
+	for _, code := range callingCodes {
+		if strings.HasPrefix(number, "+"+code+"0") {
+			number = "+" + code + number[len(code)+2:]
+			break
+		}
+	}
+
+	// E.164 numbers have at most 15 digits
+	if len(number) < 8 || len(number) > 16 {
+		log.WithField("phone", phone).Warn("Cannot normalize phone number")
+		return phone, false
+	}
+
+	return number, true
+}
@@ -0,0 +1,31 @@
+package form
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone      string
+		region     string
+		normalized string
+		ok         bool
+	}{
+		{"06-12345678", "NL", "+31612345678", true},
+		{"06 12 34 56 78", "BE", "+32612345678", true},
+		{"0470 12 34 56", "BE", "+32470123456", true},
+		{"(030) 123 4567", "DE", "+49301234567", true},
+		{"+31 6 12345678", "BE", "+31612345678", true},
+		{"0031612345678", "DE", "+31612345678", true},
+		{"+31 (0)6 12345678", "NL", "+31612345678", true},
+		{"+352 (0)621 123 456", "NL", "+352621123456", true},
+		{"612345678", "NL", "612345678", false},
+		{"06-CALL-ME", "NL", "06-CALL-ME", false},
+		{"0612", "NL", "0612", false},
+	}
+
+	for _, test := range tests {
+		normalized, ok := normalizePhone(test.phone, test.region)
+		if normalized != test.normalized || ok != test.ok {
+			t.Errorf("%q in %s: expected %q %t, got %q %t", test.phone, test.region, test.normalized, test.ok, normalized, ok)
+		}
+	}
+}
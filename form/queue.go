@@ -0,0 +1,86 @@
+package form
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQueueFull is returned in async mode when too many submissions are waiting to be stored
+var ErrQueueFull = errors.New("Queue is full")
+
+// job is a validated submission waiting to be stored
+type job struct {
+	message Message
+	form    Registration
+	lang    Language
+	key     string
+}
+
+func (h *handler) enqueue(j job) error {
+	select {
+	case h.queue <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (h *handler) work() {
+	defer h.workerDone.Done()
+
+	for j := range h.queue {
+		h.process(j)
+	}
+}
+
+// process stores a queued submission, retrying transient failures and keeping it
+// as a dead letter when it cannot be stored
+func (h *handler) process(j job) {
+	unlock := h.clubLocks.Lock(clubKey(j.form))
+	defer unlock()
+
+	if previous, ok := h.previousResult(j.key); ok {
+		h.config.Logger.WithField("subscriptionID", previous.SubscriptionID).Info("Queued submission already handled")
+		h.record(j.message, outcomeDuplicate, previous, nil)
+		return
+	}
+
+	var (
+		err error
+		// every attempt stores under the same ID, so an attempt that was committed after all is not stored twice
+		subscriptionID string
+	)
+	// the first attempt always runs, also when retries are disabled
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * h.config.QueueBackoff)
+		}
+
+		var result Result
+		if result, err = h.save(context.Background(), j.form, j.lang, j.key, &subscriptionID); err == nil {
+			h.config.Logger.WithField("subscriptionID", result.SubscriptionID).Info("Stored queued submission")
+			h.record(j.message, outcomeStored, result, nil)
+			return
+		}
+
+		if _, transient := err.(TransientError); !transient || attempt >= h.config.QueueRetries {
+			break
+		}
+	}
+
+	h.config.Logger.WithField("error", err).Error("Failed to store queued submission")
+	h.record(j.message, outcomeFailed, Result{}, err)
+	if deadLetterErr := h.store.SaveDeadLetter(context.Background(), j.message, err); deadLetterErr != nil {
+		h.config.Logger.WithField("error", deadLetterErr).Error("Failed to store dead letter")
+	}
+}
+
+func (h *handler) Close() {
+	if h.queue != nil {
+		close(h.queue)
+		h.workerDone.Wait()
+	}
+
+	h.callbacks.Wait()
+}
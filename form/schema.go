@@ -0,0 +1,79 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// expectedColumns are the columns the store reads or writes per table, keep them in line with
+// the migrations
+var expectedColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"inschrijving", []string{
+		"id", "inschrijfnummer", "jaar", "voornaam", "achternaam", "email", "telefoon", "vereniging", "taal",
+		"inschrijfdatum", "opmerkingen", "created_at", "updated_at", "extra", "toestemming_versie", "toestemming_op",
+		"seizoen", "bron", "tweede_voornaam", "tweede_achternaam", "tweede_email", "tweede_telefoon",
+	}},
+	{"team", []string{
+		"id", "inschrijvingsid", "teamnaam", "type", "niveau", "volgorde", "poule", "origineel_type",
+		"origineel_niveau", "beschikbaarheid", "niveau_code",
+	}},
+	{"dead_letters", []string{"id", "title", "data", "error", "created_at"}},
+}
+
+// CheckSchema verifies that the tables of the current schema have every column the store uses,
+// naming all missing columns. It reads information_schema, so it only works on Postgres.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	var missing []string
+	for _, expected := range expectedColumns {
+		existing, err := tableColumns(ctx, db, expected.table)
+		if err != nil {
+			return err
+		}
+
+		if len(existing) == 0 {
+			missing = append(missing, expected.table)
+			continue
+		}
+
+		for _, column := range expected.columns {
+			if _, ok := existing[column]; !ok {
+				missing = append(missing, expected.table+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Schema is missing %s, run the migrations", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func tableColumns(ctx context.Context, db *sql.DB, table string) (columns map[string]struct{}, err error) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+	`, table); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	columns = make(map[string]struct{})
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return
+		}
+		columns[column] = struct{}{}
+	}
+	err = rows.Err()
+
+	return
+}
@@ -0,0 +1,47 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema(t *testing.T) {
+	columns := make(map[string][]string)
+	for _, expected := range expectedColumns {
+		columns[expected.table] = expected.columns
+	}
+
+	db := sql.OpenDB(&fakeConn{columns: columns})
+	defer db.Close()
+
+	if err := CheckSchema(context.Background(), db); err != nil {
+		t.Fatalf("expected the complete schema to pass, got %v", err)
+	}
+
+	// the migration adding the team codes was not applied and dead_letters was dropped
+	columns["team"] = removeColumn(columns["team"], "niveau_code")
+	delete(columns, "dead_letters")
+
+	err := CheckSchema(context.Background(), db)
+	if err == nil {
+		t.Fatal("expected the missing column to fail the check")
+	}
+	if !strings.Contains(err.Error(), "team.niveau_code") || !strings.Contains(err.Error(), "dead_letters") {
+		t.Errorf("expected the error to name team.niveau_code and dead_letters, got %v", err)
+	}
+	if strings.Contains(err.Error(), "inschrijving") {
+		t.Errorf("expected only the missing columns to be named, got %v", err)
+	}
+}
+
+func removeColumn(columns []string, column string) (left []string) {
+	for _, c := range columns {
+		if c != column {
+			left = append(left, c)
+		}
+	}
+
+	return
+}
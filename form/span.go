@@ -0,0 +1,133 @@
+package form
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Span describes a single step of handling a message, such as parsing or storing it.
+// OpenTelemetry requires a newer Go release than the one we deploy with, until then spans are
+// handed to a SpanExporter, which logs them by default.
+type Span struct {
+	// TraceID is shared by the spans of a request, ParentID is the ID of the enclosing span and
+	// empty for the root span
+	TraceID    string
+	ID         string
+	ParentID   string
+	Name       string
+	Start      time.Time
+	Duration   time.Duration
+	Attributes map[string]interface{}
+	Err        error
+}
+
+// SpanExporter receives every finished span, it must be safe for concurrent use
+type SpanExporter interface {
+	ExportSpan(span Span)
+}
+
+// logExporter logs spans at debug level, a submission has a few of them
+type logExporter struct{}
+
+func (logExporter) ExportSpan(span Span) {
+	fields := log.Fields{}
+	for key, value := range span.Attributes {
+		fields[key] = value
+	}
+	fields["span"] = span.Name
+	fields["traceID"] = span.TraceID
+	fields["spanID"] = span.ID
+	if span.ParentID != "" {
+		fields["parentID"] = span.ParentID
+	}
+	fields["durationMs"] = float64(span.Duration) / float64(time.Millisecond)
+	if span.Err != nil {
+		fields["error"] = span.Err
+	}
+
+	log.WithFields(fields).Debug("Span finished")
+}
+
+type span struct {
+	Span
+	clock    Clock
+	exporter SpanExporter
+}
+
+// spanKey carries the current span in a context, spans started with that context become its children
+type spanKey struct{}
+
+func newSpan(ctx context.Context, name string, clock Clock, exporter SpanExporter) *span {
+	s := &span{
+		Span: Span{
+			ID:         randomHex(8),
+			Name:       name,
+			Start:      clock.Now(),
+			Attributes: map[string]interface{}{},
+		},
+		clock:    clock,
+		exporter: exporter,
+	}
+
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.TraceID, s.ParentID = parent.TraceID, parent.ID
+	} else {
+		s.TraceID = randomHex(16)
+	}
+
+	return s
+}
+
+// startSpan starts a span as child of the span in ctx and returns the context for its children
+func (h *handler) startSpan(ctx context.Context, name string) (context.Context, *span) {
+	s := newSpan(ctx, name, h.clock, h.config.SpanExporter)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) set(key string, value interface{}) {
+	s.Attributes[key] = value
+}
+
+func (s *span) end(err error) {
+	s.Duration = s.clock.Now().Sub(s.Start)
+	s.Err = err
+
+	s.exporter.ExportSpan(s.Span)
+}
+
+// RequestSpan is the root span of a request, the spans of handling its message are its children
+type RequestSpan struct {
+	span *span
+}
+
+// StartRequestSpan starts the root span of a request and returns the context to handle its message
+// with. A nil exporter logs the span, like the default of Config.SpanExporter.
+func StartRequestSpan(ctx context.Context, name string, exporter SpanExporter) (context.Context, *RequestSpan) {
+	if exporter == nil {
+		exporter = logExporter{}
+	}
+
+	s := newSpan(ctx, name, systemClock{}, exporter)
+	return context.WithValue(ctx, spanKey{}, s), &RequestSpan{s}
+}
+
+// Set adds an attribute to the span
+func (r *RequestSpan) Set(key string, value interface{}) {
+	r.span.set(key, value)
+}
+
+// End finishes the span and exports it
+func (r *RequestSpan) End(err error) {
+	r.span.end(err)
+}
+
+// randomHex returns n random bytes in hex, like the trace and span IDs of OpenTelemetry
+func randomHex(n int) string {
+	buffer := make([]byte, n)
+	rand.Read(buffer)
+	return hex.EncodeToString(buffer)
+}
@@ -0,0 +1,88 @@
+package form_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+func TestHandleExportsSpans(t *testing.T) {
+	spans := &formtest.Spans{}
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{SpanExporter: spans})
+	defer h.Close()
+
+	if _, err := h.Handle(context.Background(), validMessage()); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	if names := spans.Names(); !reflect.DeepEqual(names, []string{"parse", "store", "handle"}) {
+		t.Fatalf("expected the parse, store and handle spans, got %v", names)
+	}
+
+	handle := spans.Spans()[2]
+	if handle.Attributes["language"] != "NL" || handle.Attributes["teams"] != 1 {
+		t.Errorf("expected the language and team count on the handle span, got %v", handle.Attributes)
+	}
+	if handle.Err != nil {
+		t.Errorf("expected no error on the handle span, got %v", handle.Err)
+	}
+}
+
+func TestInvalidSubmissionEndsSpansWithError(t *testing.T) {
+	spans := &formtest.Spans{}
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{SpanExporter: spans})
+	defer h.Close()
+
+	message := validMessage()
+	delete(message.Data, "contact-email")
+	if _, err := h.Handle(context.Background(), message); err == nil {
+		t.Fatal("expected the submission without email to be rejected")
+	}
+
+	if names := spans.Names(); !reflect.DeepEqual(names, []string{"parse", "handle"}) {
+		t.Fatalf("expected the parse and handle spans, got %v", names)
+	}
+	for _, span := range spans.Spans() {
+		if span.Err == nil {
+			t.Errorf("expected the %s span to carry the error", span.Name)
+		}
+	}
+}
+
+func TestSpansOfAMessageShareTheTraceOfTheRequest(t *testing.T) {
+	spans := &formtest.Spans{}
+	h, _ := newHandler(t, formtest.NewMemoryStore(), form.Config{SpanExporter: spans})
+	defer h.Close()
+
+	ctx, request := form.StartRequestSpan(context.Background(), "hook", spans)
+	if _, err := h.Handle(ctx, validMessage()); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	request.End(nil)
+
+	if names := spans.Names(); !reflect.DeepEqual(names, []string{"parse", "store", "handle", "hook"}) {
+		t.Fatalf("expected the spans of the message and the request, got %v", names)
+	}
+
+	finished := spans.Spans()
+	parse, store, handle, root := finished[0], finished[1], finished[2], finished[3]
+	if root.ParentID != "" || root.TraceID == "" {
+		t.Errorf("expected the request span to start a trace, got %+v", root)
+	}
+	if handle.ParentID != root.ID {
+		t.Errorf("expected the handle span to be a child of the request span, got parent %q", handle.ParentID)
+	}
+	for _, span := range []form.Span{parse, store} {
+		if span.ParentID != handle.ID {
+			t.Errorf("expected the %s span to be a child of the handle span, got parent %q", span.Name, span.ParentID)
+		}
+	}
+	for _, span := range finished {
+		if span.TraceID != root.TraceID {
+			t.Errorf("expected the %s span in the trace of the request, got %q", span.Name, span.TraceID)
+		}
+	}
+}
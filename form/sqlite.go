@@ -0,0 +1,63 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+)
+
+// sqliteSchema creates the tables of a SQLite development database, with the columns of the Postgres
+// tables
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS inschrijving (
+		id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+		inschrijfnummer    VARCHAR(10) NOT NULL,
+		jaar               INTEGER NOT NULL,
+		voornaam           VARCHAR(20) NOT NULL,
+		achternaam         VARCHAR(30) NOT NULL,
+		email              VARCHAR(50) NOT NULL,
+		telefoon           VARCHAR(20) NOT NULL,
+		vereniging         VARCHAR(50) NOT NULL,
+		taal               VARCHAR(2) NOT NULL,
+		inschrijfdatum     TIMESTAMP NOT NULL,
+		opmerkingen        VARCHAR(500),
+		created_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		extra              TEXT,
+		toestemming_versie VARCHAR(20),
+		toestemming_op     TIMESTAMP,
+		seizoen            VARCHAR(9),
+		bron               VARCHAR(255),
+		tweede_voornaam    VARCHAR(20),
+		tweede_achternaam  VARCHAR(30),
+		tweede_email       VARCHAR(50),
+		tweede_telefoon    VARCHAR(20)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS inschrijfnummer_uniek ON inschrijving (inschrijfnummer);
+	CREATE TABLE IF NOT EXISTS team (
+		id               INTEGER PRIMARY KEY AUTOINCREMENT,
+		inschrijvingsid  INTEGER NOT NULL REFERENCES inschrijving (id),
+		teamnaam         VARCHAR(40) NOT NULL,
+		"type"           VARCHAR(40) NOT NULL,
+		niveau           VARCHAR(40) NOT NULL,
+		volgorde         INTEGER,
+		poule            VARCHAR(40),
+		origineel_type   VARCHAR(40),
+		origineel_niveau VARCHAR(40),
+		beschikbaarheid  VARCHAR(40),
+		niveau_code      INTEGER
+	);
+	CREATE TABLE IF NOT EXISTS dead_letters (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		title      TEXT NOT NULL,
+		data       TEXT NOT NULL,
+		error      TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)
+`
+
+// CreateSQLiteSchema creates the tables of a SQLite development database. Existing tables are kept as
+// they are, unlike Postgres the schema is not migrated: remove the database after columns were added.
+func CreateSQLiteSchema(ctx context.Context, db *sql.DB) (err error) {
+	_, err = db.ExecContext(ctx, sqliteSchema)
+	return
+}
@@ -0,0 +1,268 @@
+//go:build sqlite
+// +build sqlite
+
+package form_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// openSQLite creates an empty SQLite database with the current schema, the caller removes dir
+func openSQLite(t *testing.T) (db *sql.DB, dir string) {
+	dir, err := ioutil.TempDir("", "registration-handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db, err = sql.Open("sqlite3", filepath.Join(dir, "test.db")); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	// created twice to check that existing tables are kept
+	for i := 0; i < 2; i++ {
+		if err = form.CreateSQLiteSchema(context.Background(), db); err != nil {
+			db.Close()
+			os.RemoveAll(dir)
+			t.Fatalf("Failed to create the schema: %v", err)
+		}
+	}
+
+	return db, dir
+}
+
+func TestSQLiteEndToEnd(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	ctx := context.Background()
+	h, _ := newHandler(t, form.NewSQLiteStore(db, nil), form.Config{})
+	defer h.Close()
+
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if result.SubscriptionID != "000001" || result.Teams != 1 {
+		t.Fatalf("expected subscription 000001 with 1 team, got %+v", result)
+	}
+
+	var club, language string
+	if err = db.QueryRow(
+		"SELECT vereniging, taal FROM inschrijving WHERE inschrijfnummer = $1", result.SubscriptionID,
+	).Scan(&club, &language); err != nil {
+		t.Fatalf("Failed to read the registration: %v", err)
+	}
+	if club != "SBC2000" || language != "NL" {
+		t.Errorf("expected SBC2000 on the Dutch form, got %s and %s", club, language)
+	}
+
+	added, err := h.AddTeam(ctx, result.SubscriptionID, form.TeamRequest{Name: "Dames 1", Type: "Women", Level: "National", Language: "EN"})
+	if err != nil {
+		t.Fatalf("AddTeam failed: %v", err)
+	}
+	if added.Teams != 2 {
+		t.Errorf("expected 2 teams after adding one, got %d", added.Teams)
+	}
+
+	var teamType, level, originalType string
+	if err = db.QueryRow(
+		`SELECT "type", niveau, origineel_type FROM team WHERE teamnaam = $1`, "Dames 1",
+	).Scan(&teamType, &level, &originalType); err != nil {
+		t.Fatalf("Failed to read the added team: %v", err)
+	}
+	if teamType != "Dames" || level != "Bond 2" || originalType != "Women" {
+		t.Errorf("expected the translated team with its original type, got %s, %s and %s", teamType, level, originalType)
+	}
+
+	if err = h.Delete(ctx, result.SubscriptionID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	var teams int
+	if err = db.QueryRow("SELECT COUNT(*) FROM team").Scan(&teams); err != nil {
+		t.Fatal(err)
+	}
+	if teams != 0 {
+		t.Errorf("expected the teams to be deleted, %d are left", teams)
+	}
+}
+
+func TestSQLiteRecognizesTakenSubscriptionID(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	ctx := context.Background()
+	store := form.NewSQLiteStore(db, nil)
+
+	// another instance stored 000001 after this handler loaded the existing IDs
+	h, _ := newHandler(t, store, form.Config{})
+	defer h.Close()
+
+	other, _ := newHandler(t, store, form.Config{})
+	defer other.Close()
+
+	message := validMessage()
+	if _, err := other.Handle(ctx, message); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	message.Data["contact-club"] = "Other club"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("expected the unique violation to be retried with another ID, got %v", err)
+	}
+	if result.SubscriptionID != "000002" {
+		t.Errorf("expected the next ID 000002, got %s", result.SubscriptionID)
+	}
+}
+
+func TestSQLiteStoresTeamOrder(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	ctx := context.Background()
+	h, _ := newHandler(t, form.NewSQLiteStore(db, nil), form.Config{})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team3-name"] = "Dames 1"
+	message.Data["team3-type"] = "Dames"
+	message.Data["team3-level"] = "Regio 1"
+	if _, err := h.Handle(ctx, message); err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	rows, err := db.Query("SELECT teamnaam, volgorde FROM team ORDER BY volgorde")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var stored []string
+	for rows.Next() {
+		var name string
+		var order int
+		if err = rows.Scan(&name, &order); err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, fmt.Sprintf("%d:%s", order, name))
+	}
+
+	if strings.Join(stored, ", ") != "1:Heren 1, 3:Dames 1" {
+		t.Errorf("expected Heren 1 in slot 1 and Dames 1 in slot 3, got %v", stored)
+	}
+}
+
+func TestSQLiteLoadsTeamAvailability(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	ctx := context.Background()
+	store := form.NewSQLiteStore(db, nil)
+	h, _ := newHandler(t, store, form.Config{Days: []string{"ma", "di", "wo"}})
+	defer h.Close()
+
+	message := validMessage()
+	message.Data["team1-availability"] = "ma,wo"
+	result, err := h.Handle(ctx, message)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	registration, _, err := store.Registration(ctx, result.SubscriptionID)
+	if err != nil {
+		t.Fatalf("Registration failed: %v", err)
+	}
+	if availability := registration.Teams[0].Availability; availability != "ma,wo" {
+		t.Errorf("expected availability ma,wo to be loaded, got %q", availability)
+	}
+}
+
+func TestSQLiteRecordsCreatedAndUpdated(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	ctx := context.Background()
+	h, _ := newHandler(t, form.NewSQLiteStore(db, nil), form.Config{})
+	defer h.Close()
+
+	before := time.Now().UTC().Truncate(time.Second)
+	result, err := h.Handle(ctx, validMessage())
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	var submitted, created, updated time.Time
+	read := func() {
+		if err := db.QueryRow(
+			"SELECT inschrijfdatum, created_at, updated_at FROM inschrijving WHERE inschrijfnummer = $1", result.SubscriptionID,
+		).Scan(&submitted, &created, &updated); err != nil {
+			t.Fatalf("Failed to read the registration: %v", err)
+		}
+	}
+
+	// the submit time comes from the clock of the handler, the row times from the store
+	read()
+	if !submitted.Equal(submitTime) {
+		t.Errorf("expected inschrijfdatum %v, got %v", submitTime, submitted)
+	}
+	if created.Before(before) || !updated.Equal(created) {
+		t.Errorf("expected created_at and updated_at at the time of writing, got %v and %v", created, updated)
+	}
+
+	time.Sleep(time.Second)
+	if _, err = h.AddTeam(ctx, result.SubscriptionID, form.TeamRequest{Name: "Dames 1", Type: "Dames", Level: "Regio 1"}); err != nil {
+		t.Fatalf("AddTeam failed: %v", err)
+	}
+
+	firstCreated := created
+	read()
+	if !created.Equal(firstCreated) || !updated.After(created) {
+		t.Errorf("expected only updated_at to change, got %v and %v", created, updated)
+	}
+}
+
+func TestMigrateSQLiteCreatesTables(t *testing.T) {
+	db, dir := openSQLite(t)
+	defer os.RemoveAll(dir)
+	defer db.Close()
+
+	for _, table := range []string{"inschrijving", "team", "dead_letters", "schema_migrations"} {
+		var name string
+		if err := db.QueryRow(
+			"SELECT name FROM sqlite_master WHERE type = 'table' AND name = $1", table,
+		).Scan(&name); err != nil {
+			t.Errorf("expected table %s: %v", table, err)
+		}
+	}
+
+	// openSQLite migrated twice, the second time applied nothing
+	var versions, distinct int
+	if err := db.QueryRow(
+		"SELECT COUNT(*), COUNT(DISTINCT version) FROM schema_migrations",
+	).Scan(&versions, &distinct); err != nil {
+		t.Fatal(err)
+	}
+	if versions == 0 || versions != distinct {
+		t.Errorf("expected every migration to be recorded once, got %d records of %d versions", versions, distinct)
+	}
+}
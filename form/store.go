@@ -0,0 +1,731 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// Store persists registrations
+type Store interface {
+	// SaveRegistration stores the form and returns the number of teams written, failing with
+	// ErrSeasonFull when the season already has maxRegistrations registrations (0 is unlimited)
+	SaveRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, maxRegistrations int) (int, error)
+	ExistingSubscriptionIDs(ctx context.Context) (map[string]struct{}, error)
+	SubscriptionIDExists(ctx context.Context, subscriptionID string) (bool, error)
+	SaveDeadLetter(ctx context.Context, message Message, cause error) error
+	// Clubs summarizes a page of the clubs registered in the given year and counts all of them
+	Clubs(ctx context.Context, year int, limit int, offset int) ([]ClubSummary, int, error)
+	// AddTeam adds a team to an existing subscription and returns its number of teams, failing with
+	// ErrTooManyOfType when it has maxOfType teams of the same type already (0 is unlimited)
+	AddTeam(ctx context.Context, subscriptionID string, team Team, maxTeams int, maxOfType int) (int, error)
+	ChangeSubscriptionID(ctx context.Context, oldID string, newID string) error
+	// Registration loads a stored subscription with its teams in form order
+	Registration(ctx context.Context, subscriptionID string) (Registration, Language, error)
+	// DeleteRegistration removes a subscription together with its teams
+	DeleteRegistration(ctx context.Context, subscriptionID string) error
+	// TrialRegistration stores the form in the tables of schema and returns the stored registration
+	// without keeping it
+	TrialRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, schema string) (map[string]interface{}, error)
+}
+
+// ClubSummary describes the registrations of a single club
+type ClubSummary struct {
+	Club  string `json:"club"`
+	Teams int    `json:"teams"`
+}
+
+type sqlStore struct {
+	db       *sql.DB
+	clock    Clock
+	location *time.Location
+	// sqlite avoids the Postgres only statements, see NewSQLiteStore
+	sqlite bool
+}
+
+// NewSQLStore creates a Store backed by the given Postgres database that records when rows are
+// written in location, which should be the location of the handler
+func NewSQLStore(db *sql.DB, location *time.Location) Store {
+	return NewSQLStoreWith(db, systemClock{}, location)
+}
+
+// NewSQLStoreWith creates a SQL store that tells the time of writes with clock, pass the clock
+// of the handler so created_at and inschrijfdatum agree
+func NewSQLStoreWith(db *sql.DB, clock Clock, location *time.Location) Store {
+	if location == nil {
+		location = time.UTC
+	}
+	return &sqlStore{db: db, clock: clock, location: location}
+}
+
+// NewSQLiteStore creates a Store backed by a SQLite database created by CreateSQLiteSchema, meant
+// for development and tests. The season cap is not serialized like on Postgres, a concurrent
+// submission fails as transient instead, and trial registrations are not supported.
+func NewSQLiteStore(db *sql.DB, location *time.Location) Store {
+	store := NewSQLStoreWith(db, systemClock{}, location).(*sqlStore)
+	store.sqlite = true
+	return store
+}
+
+// now formats the current time like inschrijfdatum, in the configured location rather than the
+// zone of the process
+func (s *sqlStore) now() string {
+	return s.clock.Now().In(s.location).Format("2006-01-02 15:04:05")
+}
+
+func (s *sqlStore) ExistingSubscriptionIDs(ctx context.Context) (subscriptionIDs map[string]struct{}, err error) {
+	subscriptionIDs = make(map[string]struct{})
+	var rows *sql.Rows
+	if rows, err = s.db.QueryContext(ctx, "SELECT inschrijfnummer FROM inschrijving"); err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subscriptionID string
+		if err = rows.Scan(&subscriptionID); err != nil {
+			return
+		}
+		subscriptionIDs[subscriptionID] = struct{}{}
+	}
+	err = rows.Err()
+
+	return
+}
+
+func (s *sqlStore) SubscriptionIDExists(ctx context.Context, subscriptionID string) (exists bool, err error) {
+	err = s.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM inschrijving WHERE inschrijfnummer = $1)",
+		subscriptionID,
+	).Scan(&exists)
+
+	return
+}
+
+func (s *sqlStore) SaveRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, maxRegistrations int) (teams int, err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+		log.WithField("error", err).Error("Failed to start transaction")
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, teams, err = s.insertRegistration(ctx, tx, form, subscriptionID, language, maxRegistrations); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.WithField("error", err).Error("Failed to commit transaction")
+	}
+
+	return
+}
+
+// TrialRegistration inserts the form into the tables of schema, reads the registration back and
+// rolls everything back, which exercises the database without leaving data behind
+func (s *sqlStore) TrialRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, schema string) (row map[string]interface{}, err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+		log.WithField("error", err).Error("Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	if s.sqlite {
+		err = errors.New("Trial registrations need the schemas of Postgres")
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, "SET LOCAL search_path TO "+pq.QuoteIdentifier(schema)); err != nil {
+		return
+	}
+
+	var id int64
+	if id, _, err = s.insertRegistration(ctx, tx, form, subscriptionID, language, 0); err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = tx.QueryContext(ctx, "SELECT * FROM inschrijving WHERE id = $1", id); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var columns []string
+	if columns, err = rows.Columns(); err != nil {
+		return
+	}
+
+	if !rows.Next() {
+		err = rows.Err()
+		return
+	}
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		return
+	}
+
+	row = make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			values[i] = string(b)
+		}
+		row[column] = values[i]
+	}
+
+	return
+}
+
+// capLock is the advisory lock that serializes the registration count per year, its second key is the year
+const capLock = 0x53424332
+
+// insertRegistration inserts the registration and its teams within tx
+func (s *sqlStore) insertRegistration(ctx context.Context, tx *sql.Tx, form Registration, subscriptionID string, language Language, maxRegistrations int) (id int64, teams int, err error) {
+	if maxRegistrations > 0 {
+		// concurrent submissions could both count one below the cap, so they count one at a time,
+		// the lock is released when tx ends; SQLite already has a single writer
+		if !s.sqlite {
+			if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, $2)", capLock, form.Year); err != nil {
+				return
+			}
+		}
+
+		var registrations int
+		if err = tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM inschrijving WHERE jaar = $1",
+			form.Year,
+		).Scan(&registrations); err != nil {
+			return
+		}
+
+		if registrations >= maxRegistrations {
+			err = ErrSeasonFull
+			return
+		}
+	}
+
+	query := `
+		INSERT INTO inschrijving (
+			inschrijfnummer, jaar, voornaam, achternaam, email, telefoon, vereniging, taal, inschrijfdatum, opmerkingen,
+			created_at, updated_at, extra, toestemming_versie, toestemming_op, seizoen, bron,
+			tweede_voornaam, tweede_achternaam, tweede_email, tweede_telefoon
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	`
+
+	log.WithFields(log.Fields(map[string]interface{}{
+		"query":          query,
+		"subscriptionID": subscriptionID,
+		"year":           form.Year,
+		"season":         form.Season,
+		"name":           form.Name,
+		"surname":        form.Surname,
+		"email":          form.Email,
+		"phone":          form.Phone,
+		"club":           form.Club,
+		"notes":          form.Notes,
+		"extra":          form.Extra,
+		"language":       language.Code(),
+		"submitTime":     form.SubmitTime,
+		"source":         form.Source,
+	})).Info("Insert inschrijving")
+
+	var extra sql.NullString
+	if len(form.Extra) > 0 {
+		var encoded []byte
+		if encoded, err = json.Marshal(form.Extra); err != nil {
+			return
+		}
+		extra = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	var consentVersion, consentTime sql.NullString
+	if form.ConsentVersion != "" {
+		consentVersion = sql.NullString{String: trim(form.ConsentVersion, 20), Valid: true}
+		consentTime = sql.NullString{String: form.ConsentTime.Format("2006-01-02 15:04:05"), Valid: true}
+	}
+
+	var second [4]sql.NullString
+	if c := form.SecondContact; c != nil {
+		second = [4]sql.NullString{
+			{String: trim(c.Name, 20), Valid: true},
+			{String: trim(c.Surname, 30), Valid: true},
+			{String: trim(c.Email, 50), Valid: true},
+			{String: trim(c.Phone, 20), Valid: true},
+		}
+	}
+
+	args := []interface{}{
+		trim(subscriptionID, 10),
+		form.Year,
+		trim(form.Name, 20),
+		trim(form.Surname, 30),
+		trim(form.Email, 50),
+		trim(form.Phone, 20),
+		trim(form.Club, 50),
+		language.Code(),
+		form.SubmitTime.Format("2006-01-02 15:04:05"),
+		trim(form.Notes, 500),
+		s.now(),
+		extra,
+		consentVersion,
+		consentTime,
+		sql.NullString{String: form.Season, Valid: form.Season != ""},
+		sql.NullString{String: trim(form.Source, 255), Valid: form.Source != ""},
+		second[0],
+		second[1],
+		second[2],
+		second[3],
+	}
+
+	// the SQLite of the driver predates RETURNING, Postgres has no LastInsertId
+	if s.sqlite {
+		var res sql.Result
+		if res, err = tx.ExecContext(ctx, query, args...); err == nil {
+			id, err = res.LastInsertId()
+		}
+	} else {
+		err = tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+	}
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create subscription")
+		return
+	}
+
+	placeholders := make([]string, 0, len(form.Teams))
+	values := make([]interface{}, 0, 9*len(form.Teams)+1)
+	values = append(values, id)
+
+	for i, team := range form.Teams {
+		placeholders = append(
+			placeholders,
+			fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				9*i+2, 9*i+3, 9*i+4, 9*i+5, 9*i+6, 9*i+7, 9*i+8, 9*i+9, 9*i+10),
+		)
+		values = append(
+			values,
+			trim(team.Name, 40),
+			trim(team.Type, 40),
+			trim(team.Level, 40),
+			team.Slot,
+			trim(team.Poule, 40),
+			trim(team.OriginalType, 40),
+			trim(team.OriginalLevel, 40),
+			trim(team.Availability, 40),
+			levelCode(team),
+		)
+	}
+
+	query = `
+		INSERT INTO team (
+			inschrijvingsid, teamnaam, "type", niveau, volgorde, poule, origineel_type, origineel_niveau, beschikbaarheid,
+			niveau_code
+		) VALUES
+	` + strings.Join(placeholders, ",")
+
+	log.WithFields(log.Fields(map[string]interface{}{
+		"query":  query,
+		"values": values,
+	})).Info("Inserting teams")
+
+	var res sql.Result
+	if res, err = tx.ExecContext(ctx, query, values...); err != nil {
+		log.WithField("error", err).Error("Failed to create teams")
+		return
+	}
+
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		log.WithField("error", err).Error("Failed to count teams")
+		return
+	}
+
+	// the transaction is rolled back rather than keeping a registration with missing teams
+	if affected != int64(len(form.Teams)) {
+		err = fmt.Errorf("Stored %d of %d teams", affected, len(form.Teams))
+		log.WithField("error", err).Error("Failed to create teams")
+		return
+	}
+
+	teams = int(affected)
+
+	return
+}
+
+// SaveDeadLetter keeps a submission that could not be handled so it can be recovered manually
+func (s *sqlStore) SaveDeadLetter(ctx context.Context, message Message, cause error) (err error) {
+	var data []byte
+	if data, err = json.Marshal(message.Data); err != nil {
+		return
+	}
+
+	query := `
+		INSERT INTO dead_letters (title, data, error, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err = s.db.ExecContext(ctx, query, message.Title, string(data), cause.Error(), s.now())
+
+	return
+}
+
+func (s *sqlStore) Clubs(ctx context.Context, year int, limit int, offset int) (clubs []ClubSummary, total int, err error) {
+	if err = s.db.QueryRowContext(ctx,
+		"SELECT COUNT(DISTINCT vereniging) FROM inschrijving WHERE jaar = $1",
+		year,
+	).Scan(&total); err != nil {
+		return
+	}
+
+	query := `
+		SELECT i.vereniging, COUNT(t.teamnaam)
+		FROM inschrijving i
+		LEFT JOIN team t ON t.inschrijvingsid = i.id
+		WHERE i.jaar = $1
+		GROUP BY i.vereniging
+		ORDER BY i.vereniging
+		LIMIT $2 OFFSET $3
+	`
+
+	var rows *sql.Rows
+	if rows, err = s.db.QueryContext(ctx, query, year, limit, offset); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	clubs = []ClubSummary{}
+	for rows.Next() {
+		var club ClubSummary
+		if err = rows.Scan(&club.Club, &club.Teams); err != nil {
+			return
+		}
+		clubs = append(clubs, club)
+	}
+	err = rows.Err()
+
+	return
+}
+
+func (s *sqlStore) AddTeam(ctx context.Context, subscriptionID string, team Team, maxTeams int, maxOfType int) (teams int, err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+		log.WithField("error", err).Error("Failed to start transaction")
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int64
+	if err = tx.QueryRowContext(ctx,
+		"SELECT id FROM inschrijving WHERE inschrijfnummer = $1",
+		subscriptionID,
+	).Scan(&id); err == sql.ErrNoRows {
+		err = ErrSubscriptionNotFound
+		return
+	} else if err != nil {
+		return
+	}
+
+	var slot int
+	if err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*), COALESCE(MAX(volgorde), 0) FROM team WHERE inschrijvingsid = $1",
+		id,
+	).Scan(&teams, &slot); err != nil {
+		return
+	}
+
+	if teams >= maxTeams {
+		err = ErrTooManyTeams
+		return
+	}
+
+	if maxOfType > 0 {
+		var ofType int
+		if err = tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM team WHERE inschrijvingsid = $1 AND "type" = $2`,
+			id, trim(team.Type, 40),
+		).Scan(&ofType); err != nil {
+			return
+		}
+
+		if ofType >= maxOfType {
+			err = ErrTooManyOfType
+			return
+		}
+	}
+
+	query := `
+		INSERT INTO team (
+			inschrijvingsid, teamnaam, "type", niveau, volgorde, poule, origineel_type, origineel_niveau, beschikbaarheid,
+			niveau_code
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	var res sql.Result
+	if res, err = tx.ExecContext(ctx, query,
+		id,
+		trim(team.Name, 40),
+		trim(team.Type, 40),
+		trim(team.Level, 40),
+		slot+1,
+		trim(team.Poule, 40),
+		trim(team.OriginalType, 40),
+		trim(team.OriginalLevel, 40),
+		trim(team.Availability, 40),
+		levelCode(team),
+	); err != nil {
+		log.WithField("error", err).Error("Failed to create team")
+		return
+	}
+
+	var affected int64
+	if affected, err = res.RowsAffected(); err == nil && affected != 1 {
+		err = fmt.Errorf("Stored %d of 1 teams", affected)
+	}
+	if err != nil {
+		log.WithField("error", err).Error("Failed to create team")
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE inschrijving SET updated_at = $1 WHERE id = $2",
+		s.now(), id,
+	); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.WithField("error", err).Error("Failed to commit transaction")
+		return
+	}
+
+	teams++
+
+	return
+}
+
+func (s *sqlStore) ChangeSubscriptionID(ctx context.Context, oldID string, newID string) (err error) {
+	var res sql.Result
+	if res, err = s.db.ExecContext(ctx,
+		"UPDATE inschrijving SET inschrijfnummer = $1, updated_at = $2 WHERE inschrijfnummer = $3",
+		trim(newID, 10), s.now(), oldID,
+	); err != nil {
+		return
+	}
+
+	var affected int64
+	if affected, err = res.RowsAffected(); err == nil && affected == 0 {
+		err = ErrSubscriptionNotFound
+	}
+
+	return
+}
+
+func (s *sqlStore) Registration(ctx context.Context, subscriptionID string) (form Registration, lang Language, err error) {
+	var (
+		id     int64
+		code   string
+		second Contact
+	)
+	if err = s.db.QueryRowContext(ctx, `
+		SELECT id, jaar, voornaam, achternaam, email, telefoon, vereniging, taal, inschrijfdatum, COALESCE(opmerkingen, ''),
+			COALESCE(tweede_voornaam, ''), COALESCE(tweede_achternaam, ''), COALESCE(tweede_email, ''), COALESCE(tweede_telefoon, '')
+		FROM inschrijving
+		WHERE inschrijfnummer = $1
+	`, subscriptionID).Scan(
+		&id, &form.Year, &form.Name, &form.Surname, &form.Email, &form.Phone, &form.Club, &code, &form.SubmitTime, &form.Notes,
+		&second.Name, &second.Surname, &second.Email, &second.Phone,
+	); err == sql.ErrNoRows {
+		err = ErrSubscriptionNotFound
+		return
+	} else if err != nil {
+		return
+	}
+
+	if second != (Contact{}) {
+		form.SecondContact = &second
+	}
+
+	lang = languageOfCode(code)
+
+	var rows *sql.Rows
+	if rows, err = s.db.QueryContext(ctx, `
+		SELECT teamnaam, "type", niveau, COALESCE(volgorde, 0), COALESCE(poule, ''), COALESCE(beschikbaarheid, '')
+		FROM team
+		WHERE inschrijvingsid = $1
+		ORDER BY volgorde, id
+	`, id); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Team
+		if err = rows.Scan(&t.Name, &t.Type, &t.Level, &t.Slot, &t.Poule, &t.Availability); err != nil {
+			return
+		}
+		form.Teams = append(form.Teams, t)
+	}
+	err = rows.Err()
+
+	return
+}
+
+func (s *sqlStore) DeleteRegistration(ctx context.Context, subscriptionID string) (err error) {
+	var tx *sql.Tx
+	if tx, err = s.db.BeginTx(ctx, nil); err != nil {
+		log.WithField("error", err).Error("Failed to start transaction")
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var id int64
+	if err = tx.QueryRowContext(ctx,
+		"SELECT id FROM inschrijving WHERE inschrijfnummer = $1",
+		subscriptionID,
+	).Scan(&id); err == sql.ErrNoRows {
+		err = ErrSubscriptionNotFound
+		return
+	} else if err != nil {
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM team WHERE inschrijvingsid = $1", id); err != nil {
+		log.WithField("error", err).Error("Failed to delete teams")
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM inschrijving WHERE id = $1", id); err != nil {
+		log.WithField("error", err).Error("Failed to delete subscription")
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.WithField("error", err).Error("Failed to commit transaction")
+	}
+
+	return
+}
+
+type dryRunStore struct{}
+
+// NewDryRunStore creates a Store that only logs what would have been stored
+func NewDryRunStore() Store {
+	return dryRunStore{}
+}
+
+func (dryRunStore) ExistingSubscriptionIDs(ctx context.Context) (map[string]struct{}, error) {
+	return make(map[string]struct{}), nil
+}
+
+func (dryRunStore) SubscriptionIDExists(ctx context.Context, subscriptionID string) (bool, error) {
+	return false, nil
+}
+
+func (dryRunStore) SaveRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, maxRegistrations int) (int, error) {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"subscriptionID": subscriptionID,
+		"language":       language.Code(),
+		"form":           form,
+	})).Info("Dry run, not saving registration")
+
+	return len(form.Teams), nil
+}
+
+func (dryRunStore) SaveDeadLetter(ctx context.Context, message Message, cause error) error {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"message": message,
+		"cause":   cause,
+	})).Info("Dry run, not saving dead letter")
+
+	return nil
+}
+
+func (dryRunStore) Clubs(ctx context.Context, year int, limit int, offset int) ([]ClubSummary, int, error) {
+	return []ClubSummary{}, 0, nil
+}
+
+func (dryRunStore) AddTeam(ctx context.Context, subscriptionID string, team Team, maxTeams int, maxOfType int) (int, error) {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"subscriptionID": subscriptionID,
+		"team":           team,
+	})).Info("Dry run, not adding team")
+
+	return 0, ErrSubscriptionNotFound
+}
+
+func (dryRunStore) ChangeSubscriptionID(ctx context.Context, oldID string, newID string) error {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"old": oldID,
+		"new": newID,
+	})).Info("Dry run, not changing subscription ID")
+
+	return ErrSubscriptionNotFound
+}
+
+func (dryRunStore) Registration(ctx context.Context, subscriptionID string) (Registration, Language, error) {
+	return Registration{}, "", ErrSubscriptionNotFound
+}
+
+func (dryRunStore) DeleteRegistration(ctx context.Context, subscriptionID string) error {
+	log.WithField("subscriptionID", subscriptionID).Info("Dry run, not deleting subscription")
+
+	return ErrSubscriptionNotFound
+}
+
+func (dryRunStore) TrialRegistration(ctx context.Context, form Registration, subscriptionID string, language Language, schema string) (map[string]interface{}, error) {
+	return trialRow(form, subscriptionID, language), nil
+}
+
+// levelCode stores a team without a level code as NULL
+func levelCode(team Team) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(team.LevelCode), Valid: team.LevelCode != 0}
+}
+
+func trim(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+
+	return s[:maxLen]
+}
+
+// trialRow describes a registration the way TrialRegistration of the SQL store returns it
+func trialRow(form Registration, subscriptionID string, language Language) map[string]interface{} {
+	return map[string]interface{}{
+		"inschrijfnummer": subscriptionID,
+		"jaar":            form.Year,
+		"voornaam":        form.Name,
+		"achternaam":      form.Surname,
+		"email":           form.Email,
+		"telefoon":        form.Phone,
+		"vereniging":      form.Club,
+		"taal":            language.Code(),
+		"inschrijfdatum":  form.SubmitTime,
+		"opmerkingen":     form.Notes,
+	}
+}
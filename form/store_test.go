@@ -0,0 +1,129 @@
+package form
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrimLimitsNotes(t *testing.T) {
+	notes := strings.Repeat("a", 600)
+	if trimmed := trim(notes, 500); len(trimmed) != 500 {
+		t.Errorf("expected the notes to be cut to 500 characters, got %d", len(trimmed))
+	}
+	if trimmed := trim("short", 500); trimmed != "short" {
+		t.Errorf("expected short notes to be kept, got %q", trimmed)
+	}
+}
+
+func TestStoreTimesInLocation(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	clock := fixedClock(time.Date(2018, time.May, 1, 22, 30, 0, 0, time.UTC))
+	if now := NewSQLStoreWith(nil, clock, nil).(*sqlStore).now(); now != "2018-05-01 22:30:00" {
+		t.Errorf("expected UTC by default, got %s", now)
+	}
+	if now := NewSQLStoreWith(nil, clock, amsterdam).(*sqlStore).now(); now != "2018-05-02 00:30:00" {
+		t.Errorf("expected the time in Amsterdam, got %s", now)
+	}
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestSaveRegistrationRollsBackMissingTeams(t *testing.T) {
+	// the database reports fewer inserted teams than were submitted
+	conn := &fakeConn{teamsAffected: 1}
+	db := sql.OpenDB(conn)
+	defer db.Close()
+
+	registration := Registration{
+		Year:  2018,
+		Club:  "SBC2000",
+		Teams: []Team{{Slot: 1, Name: "Heren 1"}, {Slot: 2, Name: "Heren 2"}},
+	}
+	if _, err := NewSQLiteStore(db, nil).SaveRegistration(context.Background(), registration, "000001", nl, 0); err == nil {
+		t.Fatal("expected an error when teams are missing")
+	}
+	if conn.committed || !conn.rolledBack {
+		t.Errorf("expected a rollback without commit, got committed %t and rolled back %t", conn.committed, conn.rolledBack)
+	}
+}
+
+// fakeConn is a database connection accepting every statement, the team insert reports
+// teamsAffected rows and a query returns the columns of the table given as its argument
+type fakeConn struct {
+	teamsAffected int64
+	columns       map[string][]string
+	committed     bool
+	rolledBack    bool
+}
+
+func (c *fakeConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeConn) Driver() driver.Driver                        { return nil }
+func (c *fakeConn) Close() error                                 { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                    { return c, nil }
+func (c *fakeConn) Commit() error                                { c.committed = true; return nil }
+func (c *fakeConn) Rollback() error                              { c.rolledBack = true; return nil }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{c, query}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "INSERT INTO team") {
+		return fakeResult(s.conn.teamsAffected), nil
+	}
+	return fakeResult(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if len(args) != 1 {
+		return nil, errors.New("not supported")
+	}
+
+	table, _ := args[0].(string)
+	return &fakeRows{values: s.conn.columns[table]}, nil
+}
+
+// fakeRows returns values as rows of a single column
+type fakeRows struct {
+	values []string
+}
+
+func (r *fakeRows) Columns() []string { return []string{"column_name"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+// fakeResult reports itself as the number of affected rows
+type fakeResult int64
+
+func (r fakeResult) LastInsertId() (int64, error) { return 1, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return int64(r), nil }
@@ -0,0 +1,92 @@
+package form
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrSubscriptionNotFound is returned for an unknown subscription ID
+	ErrSubscriptionNotFound = errors.New("Subscription not found")
+	// ErrTooManyTeams is returned when a subscription already has the maximum number of teams
+	ErrTooManyTeams = errors.New("Subscription has the maximum number of teams")
+	// ErrTooManyOfType is returned when a subscription already has the maximum number of teams of
+	// the type of an added team
+	ErrTooManyOfType = errors.New("Subscription has the maximum number of teams of this type")
+	// ErrSeasonFull is returned when the season has the maximum number of registrations
+	ErrSeasonFull = errors.New("Registration is closed, the season is full")
+	// ErrDuplicate is returned together with the earlier result for a resubmission in conflict mode
+	ErrDuplicate = errors.New("Submission already registered")
+)
+
+// TeamRequest describes a team added to an existing subscription
+type TeamRequest struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Level string `json:"level"`
+	Poule string `json:"poule"`
+	// Availability holds comma separated day codes, like the availability field of the form
+	Availability string `json:"availability"`
+	// Language is EN when type and level are given in English, otherwise they are stored as is
+	Language string `json:"language"`
+}
+
+// AddTeam validates the team like a team of a submitted form, so the same translations, level
+// codes and poules apply, and stores it within the total and per type caps
+func (h *handler) AddTeam(ctx context.Context, subscriptionID string, request TeamRequest) (result Result, err error) {
+	lang := nl
+	if request.Language == string(en) {
+		lang = en
+	}
+
+	// the request is read as the first team slot of a form
+	fields := h.config.Fields
+	data := map[string]string{
+		fmt.Sprintf(fields.TeamName, 1):         request.Name,
+		fmt.Sprintf(fields.TeamType, 1):         request.Type,
+		fmt.Sprintf(fields.TeamLevel, 1):        request.Level,
+		fmt.Sprintf(fields.TeamPoule, 1):        request.Poule,
+		fmt.Sprintf(fields.TeamAvailability, 1): request.Availability,
+	}
+
+	var parsed *Team
+	var warnings []string
+	if parsed, warnings, err = parseTeam(data, lang, h.config, 1); err != nil {
+		err = ValidationErrors{err.Error()}
+		return
+	}
+	if parsed == nil {
+		err = ValidationErrors{localize(lang, msgMissingValue, "name")}
+		return
+	}
+
+	var teams int
+	if teams, err = h.store.AddTeam(ctx, subscriptionID, *parsed, h.config.MaxTeams, h.config.MaxTeamsPerType[parsed.Type]); err != nil {
+		if err == ErrTooManyOfType {
+			err = ValidationErrors{localize(lang, msgTooManyOfType, h.config.MaxTeamsPerType[parsed.Type], parsed.Type)}
+			return
+		}
+
+		h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+			"error":          err,
+			"subscriptionID": subscriptionID,
+		})).Error("Failed to add team")
+		return
+	}
+
+	h.config.Logger.WithFields(log.Fields(map[string]interface{}{
+		"subscriptionID": subscriptionID,
+		"teams":          teams,
+	})).Info("Added team")
+
+	result = Result{
+		SubscriptionID: subscriptionID,
+		Teams:          teams,
+		Warnings:       warnings,
+	}
+
+	return
+}
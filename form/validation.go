@@ -0,0 +1,86 @@
+package form
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ValidationErrors lists every problem found in a submission
+type ValidationErrors []string
+
+func (v ValidationErrors) Error() string {
+	return strings.Join(v, "; ")
+}
+
+// err returns nil when no problems were found so callers can return it directly
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+// TransientError wraps a storage failure that may succeed when the submission is retried
+type TransientError struct {
+	Err error
+}
+
+func (t TransientError) Error() string {
+	return "Transient failure: " + t.Err.Error()
+}
+
+// TooSoonError is returned for a submission of a club that submitted within the MinSubmitInterval
+type TooSoonError struct {
+	// Message explains the rejection to the club in the language of the form
+	Message string
+	// RetryAfter is how long the club has to wait before it may submit again
+	RetryAfter time.Duration
+}
+
+func (t TooSoonError) Error() string {
+	return "Submitted too soon, retry after " + t.RetryAfter.String()
+}
+
+// isTransient reports whether err is caused by a temporary database or network problem
+func isTransient(err error) bool {
+	if err == driver.ErrBadConn || err == context.DeadlineExceeded {
+		return true
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code.Class() {
+		case "08", // connection exception
+			"40", // transaction rollback, e.g. serialization failure or deadlock
+			"53", // insufficient resources, e.g. too many connections
+			"57": // operator intervention, e.g. database shutting down
+			return true
+		}
+	}
+
+	// SQLite allows a single writer, the others fail while it holds the lock; its errors are only
+	// told apart by their text so the form package does not depend on the cgo driver
+	if strings.HasPrefix(err.Error(), "database is locked") {
+		return true
+	}
+
+	return false
+}
+
+// isUniqueViolation reports whether err is caused by a duplicate value in a unique column
+func isUniqueViolation(err error) bool {
+	if pqErr, ok := err.(*pq.Error); ok {
+		return pqErr.Code == "23505"
+	}
+
+	return strings.HasPrefix(err.Error(), "UNIQUE constraint failed")
+}
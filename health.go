@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pinger checks the connection to the database, *sql.DB is one
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// healthHandler only tells that the process is alive, see readyHandler for the database
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	log.WithField("method", r.Method).Info("/health")
+
+	w.Header().Set("content-type", textContentType)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.WithField("error", err).Error("Failed to handle health request")
+		http.Error(w, "Could not return health OK", http.StatusInternalServerError)
+	}
+}
+
+// readyHandler answers 503 while the database cannot be reached
+func readyHandler(db pinger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.WithField("method", r.Method).Info("/ready")
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		if err := db.PingContext(ctx); err != nil {
+			log.WithField("error", err).Error("Database is unreachable")
+			http.Error(w, "Database Unreachable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("content-type", textContentType)
+		w.Write([]byte("OK"))
+	}
+}
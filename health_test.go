@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeDB answers a ping with err
+type fakeDB struct {
+	err error
+}
+
+func (db fakeDB) PingContext(ctx context.Context) error {
+	return db.err
+}
+
+func TestHealth(t *testing.T) {
+	rec := httptest.NewRecorder()
+	healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+		t.Errorf("expected 200 OK, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"reachable", nil, http.StatusOK},
+		{"unreachable", errors.New("connection refused"), http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		readyHandler(fakeDB{test.err})(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+		if rec.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, rec.Code)
+		}
+	}
+}
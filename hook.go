@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// hookSettings configures the /hook endpoint
+type hookSettings struct {
+	formHandler form.Handler
+	tenants     []*tenant
+	// secrets are those of the default handler, the tenants have their own
+	secrets      []string
+	nonces       *nonceGuard
+	contentTypes []string
+	maxBodyBytes int64
+	strictJSON   bool
+	strictFields bool
+	// testSchema receives the test messages stored with ?store=1
+	testSchema string
+	// spanExporter receives the root span of every request, nil logs it
+	spanExporter form.SpanExporter
+}
+
+// hookHandler handles the messages of the wordpress webhook
+func hookHandler(s hookSettings) http.HandlerFunc {
+	// a request must carry the secret of the default tenant or of any other tenant, once the
+	// tenant of the message is known its secret is checked again
+	anySecrets := s.secrets
+	for _, t := range s.tenants {
+		anySecrets = append(anySecrets, t.Secrets...)
+	}
+
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+
+		if !validSecret(r.Header.Get("X-hook-secret"), anySecrets) {
+			slowDown(started)
+			log.WithField("ip", clientIP(r)).Error("Invalid secret")
+			http.Error(w, "Invalid Secret", http.StatusForbidden)
+			return
+		}
+
+		if !s.nonces.check(w, r) {
+			return
+		}
+
+		// test messages are sent by hand, so they may use any content type
+		if r.Header.Get("X-test") == "" && !acceptedContentType(r.Header.Get("Content-Type"), s.contentTypes) {
+			log.WithField("contentType", r.Header.Get("Content-Type")).Warn("Unsupported content type")
+			http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		defer r.Body.Close()
+		body := newCappedReader(r.Body, s.maxBodyBytes)
+
+		var (
+			msg    form.Message
+			err    error
+			reader io.Reader = body
+		)
+		if multipartForm(r.Header.Get("Content-Type")) {
+			msg, err = decodeMultipart(r, body)
+		} else {
+			if s.strictJSON {
+				// duplicate keys can only be found in the raw document, so it is read completely
+				buffer, err := ioutil.ReadAll(body)
+				if err != nil {
+					log.WithField("error", err).Error("Cannot read body")
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if body.exceeded() {
+					tooLarge(w, s.maxBodyBytes)
+					return
+				}
+				if err = checkDuplicateKeys(buffer); err != nil {
+					log.WithField("error", err).Error("Rejecting body with duplicate keys")
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				reader = bytes.NewReader(buffer)
+			}
+
+			msg, err = form.DecodeMessage(reader, s.strictFields)
+		}
+		if body.exceeded() {
+			tooLarge(w, s.maxBodyBytes)
+			return
+		}
+		if problems, ok := err.(form.ValidationErrors); ok {
+			log.WithField("errors", problems).Error("Cannot parse body")
+			writeJSON(w, http.StatusBadRequest, validationResponse{problems})
+			return
+		}
+		msg.Source = requestSource(r)
+
+		handler, tenantSecrets := s.formHandler, s.secrets
+		t, title, err := resolveTenant(s.tenants, r.Header.Get("X-tenant"), msg.Title)
+		if err != nil {
+			log.WithField("tenant", r.Header.Get("X-tenant")).Error("Unknown tenant")
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if t != nil {
+			log.WithField("tenant", t.Name).Info("Routing message to tenant")
+			handler, msg.Title = t.handler, title
+			if len(t.Secrets) > 0 {
+				tenantSecrets = t.Secrets
+			}
+		}
+
+		if !validSecret(r.Header.Get("X-hook-secret"), tenantSecrets) {
+			slowDown(started)
+			log.WithField("ip", clientIP(r)).Error("Invalid secret for tenant")
+			http.Error(w, "Invalid Secret", http.StatusForbidden)
+			return
+		}
+
+		if r.Header.Get("X-test") != "" {
+			log.Info("Received test message")
+
+			if msg.Data == nil {
+				msg.Data = map[string]string{}
+			}
+
+			resp := testResponse{
+				Message: "Received submission for form " + msg.Title,
+				Data:    msg.Data,
+			}
+
+			if r.URL.Query().Get("store") != "" {
+				if resp.Row, err = handler.Trial(r.Context(), msg, s.testSchema); err != nil {
+					log.WithField("error", err).Error("Failed to store test message")
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			buffer, err := json.Marshal(resp)
+			if err != nil {
+				log.WithField("error", err).Error("Failed to handle test message")
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			log.Info("Successfully handled test message")
+
+			w.Header().Set("content-type", jsonContentType)
+			w.Write(buffer)
+			return
+		} else {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"message": msg,
+				"ip":      clientIP(r),
+			})).Info("Received message")
+
+			result, err := handler.Handle(r.Context(), msg)
+			if problems, ok := err.(form.ValidationErrors); ok {
+				log.WithField("problems", problems).Error("Invalid message")
+				writeJSON(w, http.StatusBadRequest, validationResponse{problems})
+				return
+			} else if _, ok := err.(form.TransientError); ok || err == form.ErrQueueFull {
+				log.WithField("error", err).Error("Temporarily failed to handle message")
+				w.Header().Set("Retry-After", "60")
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+				return
+			} else if tooSoon, ok := err.(form.TooSoonError); ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooSoon.RetryAfter.Seconds()))))
+				http.Error(w, tooSoon.Message, http.StatusTooManyRequests)
+				return
+			} else if err == form.ErrDuplicate {
+				log.WithField("subscriptionID", result.SubscriptionID).Warn("Duplicate submission")
+				writeJSON(w, http.StatusConflict, result)
+				return
+			} else if err == form.ErrSeasonFull {
+				log.WithField("error", err).Error("Season is full")
+				http.Error(w, err.Error(), http.StatusGone)
+				return
+			} else if err != nil {
+				log.WithField("error", err).Error("Failed to handle message")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			log.WithField("title", msg.Title).Info("Successfully handled message")
+
+			status := http.StatusOK
+			if result.Queued {
+				status = http.StatusAccepted
+			}
+			respond(w, r, status, result)
+		}
+	}
+
+	// every request is the root span of the spans of handling its message
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := form.StartRequestSpan(r.Context(), "hook", s.spanExporter)
+		status := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handle(status, r.WithContext(ctx))
+		span.Set("status", status.status)
+		span.End(nil)
+	}
+}
+
+// statusWriter remembers the status code of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+const testSecret = "secret"
+
+// validBody is a complete submission of the Dutch form with a single team
+const validBody = `{"title": "Inschrijven teams", "posted_data": {
+	"contact-club": "SBC2000", "contact-name": "Jan", "contact-surname": "Jansen",
+	"contact-email": "jan@example.com", "contact-phone": "0612345678",
+	"team1-name": "Heren 1", "team1-type": "Heren", "team1-level": "Regio 1"}}`
+
+// newTestHook creates a /hook handler with the default settings on a memory store, the caller
+// closes the form handler
+func newTestHook(t *testing.T, config form.Config) (http.HandlerFunc, form.Handler, *formtest.MemoryStore) {
+	store := formtest.NewMemoryStore()
+	hook, formHandler := newTestHookWith(t, store, config)
+
+	return hook, formHandler, store
+}
+
+// newTestHookWith creates a /hook handler with the default settings on store, the caller closes
+// the form handler
+func newTestHookWith(t *testing.T, store form.Store, config form.Config) (http.HandlerFunc, form.Handler) {
+	clock := &formtest.Clock{T: time.Date(2018, time.May, 1, 12, 0, 0, 0, time.UTC)}
+	formHandler, err := form.NewHandlerWith(store, clock, &formtest.IDs{}, config)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	return hookHandler(testSettings(formHandler)), formHandler
+}
+
+// testSettings are the default settings of /hook with the test secret
+func testSettings(formHandler form.Handler) hookSettings {
+	return hookSettings{
+		formHandler:  formHandler,
+		secrets:      []string{testSecret},
+		nonces:       newNonceGuard(0),
+		contentTypes: []string{"application/json", "multipart/form-data"},
+		maxBodyBytes: 1 << 20,
+		testSchema:   "scratch",
+	}
+}
+
+// post sends body to hook with the secret and a JSON content type, headers are added as given
+func post(hook http.HandlerFunc, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+	r.Header.Set("X-hook-secret", testSecret)
+	r.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	hook(rec, r)
+	return rec
+}
+
+func TestHookRejectsMissingPostedData(t *testing.T) {
+	hook, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	for _, body := range []string{
+		`{"title": "Inschrijven teams"}`,
+		`{"title": "Inschrijven teams", "posted_data": null}`,
+		`{"title": "Inschrijven teams", "posted_data": {}}`,
+	} {
+		rec := post(hook, body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rec.Code)
+		}
+	}
+
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestHookTestMessageWithoutPostedData(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	rec := post(hook, `{"title": "Inschrijven teams"}`, "X-test", "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if data, ok := resp["data"].(map[string]interface{}); !ok || len(data) != 0 {
+		t.Errorf("expected empty data rather than null, got %v", resp["data"])
+	}
+}
+
+func TestHookContentTypes(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	tests := []struct {
+		name        string
+		body        string
+		headers     []string
+		status      int
+		contentType string
+	}{
+		{"success", validBody, nil, http.StatusOK, jsonContentType},
+		{"validation", `{"title": "Inschrijven teams", "posted_data": {}}`, nil, http.StatusBadRequest, jsonContentType},
+		{"secret", validBody, []string{"X-hook-secret", "wrong"}, http.StatusForbidden, textContentType},
+		{"test message", validBody, []string{"X-test", "1"}, http.StatusOK, jsonContentType},
+	}
+
+	for _, test := range tests {
+		rec := post(hook, test.body, test.headers...)
+		if rec.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, rec.Code)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("%s: expected %q, got %q", test.name, test.contentType, contentType)
+		}
+	}
+}
+
+func TestHookListsValidationErrors(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	body := strings.Replace(validBody, `"contact-email": "jan@example.com", "contact-phone": "0612345678",`, "", 1)
+	rec := post(hook, body)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+
+	var resp validationResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if len(resp.Errors) != 2 {
+		t.Errorf("expected the email and phone to be reported, got %q", resp.Errors)
+	}
+}
+
+// flakyStore fails to save while failures is positive, like a database that is briefly unreachable
+type flakyStore struct {
+	*formtest.MemoryStore
+	failures int
+}
+
+func (s *flakyStore) SaveRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, maxRegistrations int) (int, error) {
+	if s.failures > 0 {
+		s.failures--
+		return 0, driver.ErrBadConn
+	}
+	return s.MemoryStore.SaveRegistration(ctx, registration, subscriptionID, language, maxRegistrations)
+}
+
+func TestHookRetryAfterTransientFailure(t *testing.T) {
+	store := &flakyStore{MemoryStore: formtest.NewMemoryStore(), failures: 1}
+	hook, formHandler := newTestHookWith(t, store, form.Config{StoreRetries: -1})
+	defer formHandler.Close()
+
+	rec := post(hook, validBody)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a transient failure to return 503, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+
+	// the retry of the webhook is stored, a retry after that returns the same registration
+	var results [2]form.Result
+	for i := range results {
+		rec = post(hook, validBody)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("retry %d: expected 200, got %d", i+1, rec.Code)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &results[i]); err != nil {
+			t.Fatalf("expected a JSON response: %v", err)
+		}
+	}
+
+	if results[0].SubscriptionID == "" || results[0].SubscriptionID != results[1].SubscriptionID {
+		t.Errorf("expected both retries to return the same subscription ID, got %q and %q",
+			results[0].SubscriptionID, results[1].SubscriptionID)
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected a single registration, got %d", store.Registrations())
+	}
+}
+
+func TestHookValidationFailureIsNotRetryable(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	rec := post(hook, `{"title": "Inschrijven teams", "posted_data": {"contact-club": "SBC2000"}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Error("expected no Retry-After header for an invalid submission")
+	}
+}
+
+func TestHookAllowsOnlyPost(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	r := httptest.NewRequest(http.MethodGet, "/hook", nil)
+	r.Header.Set("X-hook-secret", testSecret)
+	rec := httptest.NewRecorder()
+	hook(rec, r)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != http.MethodPost {
+		t.Errorf("expected Allow: POST, got %q", allow)
+	}
+}
+
+func TestHookStrictJSONRejectsDuplicateKeys(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	settings := testSettings(formHandler)
+	settings.strictJSON = true
+	strict := hookHandler(settings)
+	lenient := hookHandler(testSettings(formHandler))
+
+	body := strings.Replace(validBody, `"team1-name": "Heren 1",`, `"team1-name": "Heren 1", "team1-name": "Heren 2",`, 1)
+	if rec := post(strict, body); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected duplicate keys to be rejected in strict mode, got %d", rec.Code)
+	}
+	if store.Registrations() != 0 {
+		t.Fatalf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+
+	if rec := post(strict, validBody); rec.Code != http.StatusOK {
+		t.Errorf("expected a body without duplicate keys to be accepted in strict mode, got %d", rec.Code)
+	}
+
+	// by default the last value is used
+	body = strings.Replace(body, `"contact-club": "SBC2000"`, `"contact-club": "Kinheim"`, 1)
+	rec := post(lenient, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected duplicate keys to be accepted by default, got %d", rec.Code)
+	}
+
+	var result form.Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if teams := store.Teams(result.SubscriptionID); len(teams) != 1 || teams[0] != "Heren 2" {
+		t.Errorf("expected the last value Heren 2, got %v", teams)
+	}
+}
+
+func TestHookAcceptsQueuedSubmission(t *testing.T) {
+	hook, formHandler, store := newTestHook(t, form.Config{QueueSize: 1})
+
+	rec := post(hook, validBody)
+	formHandler.Close()
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected 202, got %d", rec.Code)
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected the queued submission to be stored on close, got %d", store.Registrations())
+	}
+}
+
+func TestHookEchoesTranslatedTeams(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	body := strings.NewReplacer(
+		`"Inschrijven teams"`, `"Sign up teams"`,
+		`"team1-type": "Heren"`, `"team1-type": "Women"`,
+		`"team1-level": "Regio 1"`, `"team1-level": "National"`,
+	).Replace(validBody)
+	rec := post(hook, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var result form.Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+
+	expected := form.TeamResult{Slot: 1, Name: "Heren 1", Type: "Dames", Level: "Bond 2"}
+	if len(result.Details) != 1 || result.Details[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, result.Details)
+	}
+}
+
+func TestHookSeasonFull(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{MaxRegistrations: 1})
+	defer formHandler.Close()
+
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 under the cap, got %d", rec.Code)
+	}
+
+	body := strings.Replace(validBody, `"contact-club": "SBC2000"`, `"contact-club": "Kinheim"`, 1)
+	if rec := post(hook, body); rec.Code != http.StatusGone {
+		t.Errorf("expected 410 at the cap, got %d", rec.Code)
+	}
+}
+
+// trialStore records the schemas of the trial registrations
+type trialStore struct {
+	*formtest.MemoryStore
+	schemas []string
+}
+
+func (s *trialStore) TrialRegistration(ctx context.Context, registration form.Registration, subscriptionID string, language form.Language, schema string) (map[string]interface{}, error) {
+	s.schemas = append(s.schemas, schema)
+	return s.MemoryStore.TrialRegistration(ctx, registration, subscriptionID, language, schema)
+}
+
+func TestHookTestMessageStoresInScratchSchema(t *testing.T) {
+	store := &trialStore{MemoryStore: formtest.NewMemoryStore()}
+	hook, formHandler := newTestHookWith(t, store, form.Config{})
+	defer formHandler.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/hook?store=1", strings.NewReader(validBody))
+	r.Header.Set("X-hook-secret", testSecret)
+	r.Header.Set("X-test", "1")
+	rec := httptest.NewRecorder()
+	hook(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp testResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if resp.Row["vereniging"] != "SBC2000" || resp.Row["inschrijfnummer"] == "" {
+		t.Errorf("expected the written row, got %v", resp.Row)
+	}
+	if len(store.schemas) != 1 || store.schemas[0] != "scratch" {
+		t.Errorf("expected a trial in the scratch schema, got %v", store.schemas)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be kept, got %d registrations", store.Registrations())
+	}
+
+	// without ?store= nothing is written at all
+	post(hook, validBody, "X-test", "1")
+	if len(store.schemas) != 1 {
+		t.Errorf("expected no trial without ?store=, got %v", store.schemas)
+	}
+}
+
+func TestHookRequestContentType(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	tests := []struct {
+		contentType string
+		test        bool
+		status      int
+	}{
+		{"application/json", false, http.StatusOK},
+		{"application/json; charset=utf-8", false, http.StatusOK},
+		{"application/x-www-form-urlencoded", false, http.StatusUnsupportedMediaType},
+		{"text/plain", false, http.StatusUnsupportedMediaType},
+		{"", false, http.StatusUnsupportedMediaType},
+		// test messages are sent by hand with any content type
+		{"text/plain", true, http.StatusOK},
+	}
+
+	for i, test := range tests {
+		body := strings.Replace(validBody, `"contact-club": "SBC2000"`, `"contact-club": "Club `+strconv.Itoa(i)+`"`, 1)
+		headers := []string{"Content-Type", test.contentType}
+		if test.test {
+			headers = append(headers, "X-test", "1")
+		}
+
+		if rec := post(hook, body, headers...); rec.Code != test.status {
+			t.Errorf("%q, test %t: expected %d, got %d", test.contentType, test.test, test.status, rec.Code)
+		}
+	}
+}
+
+func TestHookStreamsBody(t *testing.T) {
+	hook, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	if rec := post(hook, validBody[:len(validBody)/2]); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a truncated body to be rejected, got %d", rec.Code)
+	}
+	if rec := post(hook, validBody+` {"title": "Inschrijven teams"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected data after the message to be rejected, got %d", rec.Code)
+	}
+
+	settings := testSettings(formHandler)
+	settings.maxBodyBytes = int64(len(validBody) - 1)
+	if rec := post(hookHandler(settings), validBody); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected a body over the limit to be rejected, got %d", rec.Code)
+	}
+
+	if store.Registrations() != 0 {
+		t.Fatalf("expected nothing to be stored, got %d registrations", store.Registrations())
+	}
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Errorf("expected a complete body to be stored, got %d", rec.Code)
+	}
+}
+
+func TestHookDuplicateModes(t *testing.T) {
+	for _, test := range []struct {
+		mode   string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"idempotent", http.StatusOK},
+		{"conflict", http.StatusConflict},
+	} {
+		hook, formHandler, store := newTestHook(t, form.Config{DuplicateMode: test.mode})
+
+		var results [2]form.Result
+		for i := range results {
+			rec := post(hook, validBody)
+			if i == 1 && rec.Code != test.status {
+				t.Errorf("%q: expected %d for the duplicate, got %d", test.mode, test.status, rec.Code)
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &results[i]); err != nil {
+				t.Fatalf("%q: expected a JSON response: %v", test.mode, err)
+			}
+		}
+		formHandler.Close()
+
+		if results[1].SubscriptionID != results[0].SubscriptionID || store.Registrations() != 1 {
+			t.Errorf("%q: expected the existing subscription %s, got %s", test.mode, results[0].SubscriptionID, results[1].SubscriptionID)
+		}
+	}
+}
+
+func TestHookTooSoon(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{MinSubmitInterval: time.Minute})
+	defer formHandler.Close()
+
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	changed := strings.Replace(validBody, `"Regio 1"`, `"Regio 2"`, 1)
+	rec := post(hook, changed)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 within the interval, got %d", rec.Code)
+	}
+	if retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || retryAfter < 1 || retryAfter > 60 {
+		t.Errorf("expected to retry within a minute, got Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestHookStoresSource(t *testing.T) {
+	for _, storeSource := range []bool{false, true} {
+		hook, formHandler, store := newTestHook(t, form.Config{StoreSource: storeSource})
+
+		rec := post(hook, validBody, "User-Agent", "WordPress/4.9.5; https://sbc2000.nl", "Referer", "https://sbc2000.nl/inschrijven")
+		formHandler.Close()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+
+		var result form.Result
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("expected a JSON response: %v", err)
+		}
+
+		registration, _, err := store.Registration(context.Background(), result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := ""
+		if storeSource {
+			expected = "WordPress/4.9.5; https://sbc2000.nl | https://sbc2000.nl/inschrijven"
+		}
+		if registration.Source != expected {
+			t.Errorf("store source %t: expected %q, got %q", storeSource, expected, registration.Source)
+		}
+	}
+}
+
+func TestHookReturnsWarnings(t *testing.T) {
+	english := strings.NewReplacer(`"Inschrijven teams"`, `"Sign up teams"`, `"Regio 1"`, `"National"`).Replace(validBody)
+
+	tests := []struct {
+		name     string
+		body     string
+		warnings int
+	}{
+		{"clean", validBody, 0},
+		{"clean English", strings.Replace(english, `"Heren"`, `"Men"`, 1), 0},
+		{"unknown type", strings.Replace(english, `"Heren"`, `"Veterans"`, 1), 1},
+		{"unusual phone", strings.Replace(validBody, "0612345678", "612345678", 1), 1},
+	}
+
+	for _, test := range tests {
+		hook, formHandler, _ := newTestHook(t, form.Config{})
+		rec := post(hook, test.body)
+		formHandler.Close()
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", test.name, rec.Code, rec.Body)
+		}
+
+		var response struct {
+			Warnings []string `json:"warnings"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: expected a JSON response: %v", test.name, err)
+		}
+		if len(response.Warnings) != test.warnings {
+			t.Errorf("%s: expected %d warnings, got %q", test.name, test.warnings, response.Warnings)
+		}
+		if test.warnings == 0 && strings.Contains(rec.Body.String(), "warnings") {
+			t.Errorf("%s: expected no warnings field, got %s", test.name, rec.Body)
+		}
+	}
+}
+
+func TestHookExportsARootSpanPerRequest(t *testing.T) {
+	spans := &formtest.Spans{}
+	_, formHandler, _ := newTestHook(t, form.Config{SpanExporter: spans})
+	defer formHandler.Close()
+
+	settings := testSettings(formHandler)
+	settings.spanExporter = spans
+	hook := hookHandler(settings)
+
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	finished := spans.Spans()
+	if len(finished) == 0 || finished[len(finished)-1].Name != "hook" {
+		t.Fatalf("expected the hook span to finish last, got %v", spans.Names())
+	}
+	root := finished[len(finished)-1]
+	if root.ParentID != "" || root.Attributes["status"] != http.StatusOK {
+		t.Errorf("expected a root span with the status, got %+v", root)
+	}
+	for _, span := range finished[:len(finished)-1] {
+		if span.TraceID != root.TraceID {
+			t.Errorf("expected the %s span in the trace of the request", span.Name)
+		}
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -15,107 +23,596 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// content types of the responses, http.Error always uses textContentType
+const (
+	jsonContentType = "application/json; charset=utf-8"
+	textContentType = "text/plain; charset=utf-8"
+	htmlContentType = "text/html; charset=utf-8"
+)
+
 type testResponse struct {
 	Message string            `json:"message"`
 	Data    map[string]string `json:"data"`
+	// Row is the registration as stored in the scratch schema, only with ?store=1
+	Row map[string]interface{} `json:"row,omitempty"`
+}
+
+type validationResponse struct {
+	Errors []string `json:"errors"`
 }
 
+// httpClient makes all outbound calls, see HTTP_TIMEOUT
+var httpClient *http.Client
+
 func main() {
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	features = parseFeatures(os.Getenv("FEATURES"))
+	httpClient = form.NewHTTPClient(envDuration("HTTP_TIMEOUT", 10*time.Second))
+
+	if envBool("REDACT_PII", features.RedactPII) {
+		log.AddHook(redactHook{})
+	}
+
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		level, err := log.ParseLevel(name)
+		if err != nil {
+			log.WithField("level", name).Fatal("Unknown log level")
+		}
+		log.SetLevel(level)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := replay(os.Args[2:]); err != nil {
+			log.WithField("error", err).Fatal("Replay failed")
+		}
+		return
+	}
+
+	db, err := openDB("")
 	if err != nil {
 		log.WithField("error", err).Fatal("Could not connect to database")
 		return
 	}
 
-	formHandler, err := form.NewHandler(db)
+	config := handlerConfig()
+	trustProxy = envBool("TRUST_PROXY", features.TrustProxy)
+	authFailureDelay = envDuration("AUTH_FAILURE_DELAY", 0)
+	authFailureJitter = envDuration("AUTH_FAILURE_JITTER", 0)
+	secrets := webhookSecrets()
+	strictJSON := envBool("STRICT_JSON", features.StrictJSON)
+	strictFields := envBool("STRICT_FIELDS", features.StrictFields)
+	testSchema := envString("TEST_SCHEMA", "scratch")
+	contentTypes := envList("CONTENT_TYPES")
+	maxBodyBytes := envInt("MAX_BODY_BYTES", 1<<20)
+	if len(contentTypes) == 0 {
+		contentTypes = []string{"application/json", "multipart/form-data"}
+	}
+
+	// SQLite databases get their tables in openDB
+	if envBool("RUN_MIGRATIONS", features.RunMigrations) && !sqliteDriver() {
+		if err = form.Migrate(context.Background(), db); err != nil {
+			log.WithField("error", err).Fatal("Could not migrate database")
+			return
+		}
+	}
+
+	if envBool("CHECK_SCHEMA", false) {
+		if err = form.CheckSchema(context.Background(), db); err != nil {
+			log.WithField("error", err).Fatal("Database schema does not match")
+			return
+		}
+	}
+
+	go watchPool(db, envDuration("DB_STATS_INTERVAL", 15*time.Second), envInt("DB_WAIT_ALERT_THRESHOLD", 0))
+
+	store := newStore(db, config.Location)
+	if size := envInt("CACHE_SIZE", 0); size > 0 {
+		store = form.NewCachedStore(store, envDuration("CACHE_TTL", 5*time.Minute), int(size))
+	}
+
+	formHandler, err := form.NewHandler(store, config)
 	if err != nil {
 		log.WithField("error", err).Fatal("Could not create formHandler")
 		return
 	}
 
-	http.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			log.WithField("method", r.Method).Error("Invalid method")
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-			return
+	tenants, err := loadTenants(config)
+	if err != nil {
+		log.WithField("error", err).Fatal("Could not load tenants")
+		return
+	}
+
+	adminTokens := envList("ADMIN_TOKENS")
+	pause := &maintenance{}
+	nonces := newNonceGuard(envDuration("REPLAY_WINDOW", 0))
+	pause.set(envBool("MAINTENANCE_MODE", false))
+
+	// a mux of our own, importing expvar registers /debug/vars on the default one
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", recoverPanics(pause.paused(hookHandler(hookSettings{
+		formHandler:  formHandler,
+		tenants:      tenants,
+		secrets:      secrets,
+		nonces:       nonces,
+		contentTypes: contentTypes,
+		maxBodyBytes: maxBodyBytes,
+		strictJSON:   strictJSON,
+		strictFields: strictFields,
+		testSchema:   testSchema,
+		spanExporter: config.SpanExporter,
+	}))))
+
+	mux.HandleFunc("/bulk", recoverPanics(pause.paused(requireSecret(secrets, compress(bulkHandler(formHandler, envInt("MAX_BULK_BYTES", 10<<20), strictFields))))))
+
+	mux.HandleFunc("/maintenance", recoverPanics(requireSecret(secrets, maintenanceHandler(pause, adminTokens))))
+
+	mux.HandleFunc("/clubs", recoverPanics(requireSecret(secrets, compress(clubsHandler(store, config)))))
+
+	mux.HandleFunc("/metrics", recoverPanics(requireSecret(secrets, metricsHandler)))
+
+	mux.HandleFunc("/audit", recoverPanics(requireSecret(secrets, compress(auditHandler(formHandler, tenants)))))
+
+	mux.HandleFunc("/subscriptions/", recoverPanics(requireSecret(secrets, subscriptionsHandler(formHandler, adminTokens, maxBodyBytes))))
+
+	mux.HandleFunc("/health", recoverPanics(healthHandler))
+
+	mux.HandleFunc("/ready", recoverPanics(readyHandler(db)))
+
+	ticker := time.NewTicker(10 * time.Minute)
+	go func() {
+		baseURL := os.Getenv("BASE_URL")
+		for range ticker.C {
+			resp, err := httpClient.Get(fmt.Sprintf("%s/%s", baseURL, "health"))
+			if err != nil {
+				log.WithField("error", err).Warn("Keepalive request failed")
+				continue
+			}
+			resp.Body.Close()
 		}
+	}()
 
-		if r.Header.Get("X-hook-secret") != os.Getenv("WEBHOOK_SECRET") {
-			log.WithField("secret", r.Header.Get("X-hook-secret")).Error("Invalid secret")
-			http.Error(w, "Invalid Secret", http.StatusForbidden)
-			return
+	server := &http.Server{Addr: fmt.Sprintf(":%s", os.Getenv("PORT")), Handler: mux}
+
+	// on shutdown finish the running requests and store the queued submissions before exiting
+	stop := make(chan os.Signal, 1)
+	stopped := make(chan struct{})
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(stopped)
+		<-stop
+		log.Info("Shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.WithField("error", err).Error("Failed to shut down server")
 		}
+	}()
 
-		var (
-			buffer []byte
-			err    error
-		)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.WithField("error", err).Fatal("Server stopped")
+	}
 
-		defer r.Body.Close()
-		if buffer, err = ioutil.ReadAll(r.Body); err != nil {
-			log.WithField("error", err).Error("Cannot read body")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+	<-stopped
+	formHandler.Close()
+	for _, t := range tenants {
+		t.handler.Close()
+	}
+}
+
+// envInt reads an integer from the environment, falling back to def when unset or invalid
+func envInt(key string, def int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"key":   key,
+			"value": value,
+		})).Warn("Invalid integer in environment, using default")
+		return def
+	}
+
+	return parsed
+}
+
+// loadBlocklist reads the email addresses and club names in file, one per line, skipping
+// empty lines and # comments
+func loadBlocklist(file string) (blocklist []string) {
+	if file == "" {
+		return
+	}
+
+	buffer, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"file":  file,
+			"error": err,
+		})).Fatal("Could not read blocklist")
+		return
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			blocklist = append(blocklist, line)
 		}
+	}
 
-		log.WithField("body", string(buffer)).Info("Request body read")
+	return
+}
 
-		var msg form.Message
-		if err = json.Unmarshal(buffer, &msg); err != nil {
-			log.WithField("error", err).Error("Cannot parse body")
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+// envString reads a string from the environment, falling back to def when unset
+func envString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// envList reads a comma separated list from the environment, skipping empty entries
+func envList(key string) (list []string) {
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
 		}
+	}
 
-		if r.Header.Get("X-test") != "" {
-			log.Info("Received test message")
+	return
+}
 
-			resp := testResponse{
-				Message: "Received submission for form " + msg.Title,
-				Data:    msg.Data,
-			}
+// envPoules reads the poules per team type from the environment, formatted as "Heren:A|B;Dames:A",
+// it also reads the regional levels per team type
+func envPoules(key string) map[string][]string {
+	poules := make(map[string][]string)
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
 
-			if buffer, err = json.Marshal(resp); err != nil {
-				log.WithField("error", err).Error("Failed to handle test message")
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+		teamType := strings.TrimSpace(parts[0])
+		for _, poule := range strings.Split(parts[1], "|") {
+			if poule = strings.TrimSpace(poule); poule != "" {
+				poules[teamType] = append(poules[teamType], poule)
 			}
+		}
+	}
 
-			log.Info("Successfully handled test message")
+	return poules
+}
 
-			w.Header().Set("content-type", "application/json")
-			w.Write(buffer)
-			return
-		} else {
-			log.WithField("message", msg).Info("Received message")
-
-			if err := formHandler.Handle(msg); err == nil {
-				log.WithField("title", msg.Title).Info("Successfully handled message")
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("OK"))
-			} else {
-				log.WithField("error", err).Error("Failed to handle message")
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+// envNumbers reads positive numbers per name from the environment, formatted as "Bond 2:20;Regio 1:31",
+// such as the codes of the levels or the maximum number of teams per type
+func envNumbers(key string) map[string]int {
+	numbers := make(map[string]int)
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		name := strings.TrimSpace(parts[0])
+		number, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || number <= 0 {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"key":    key,
+				"name":   name,
+				"number": parts[1],
+			})).Fatal("Invalid number in environment")
+		}
+		numbers[name] = number
+	}
+
+	return numbers
+}
+
+// envValuePatterns reads the patterns that translate English values from the environment, formatted
+// as "^Men\b.*=Heren;^Women\b.*=Dames" in the order they are tried, a pattern cannot contain ;
+func envValuePatterns(key string) (patterns []form.ValuePattern) {
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		separator := strings.LastIndex(entry, "=")
+		if separator < 0 {
+			continue
+		}
+
+		pattern, err := regexp.Compile(strings.TrimSpace(entry[:separator]))
+		if err != nil {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"key":   key,
+				"entry": entry,
+				"error": err,
+			})).Fatal("Invalid pattern in environment")
+		}
+
+		patterns = append(patterns, form.ValuePattern{
+			Pattern: pattern,
+			Value:   strings.TrimSpace(entry[separator+1:]),
+		})
+	}
+
+	return
+}
+
+// envExtraFields reads the additional required fields from the environment, formatted as
+// "license=^[0-9]{6}$;consent" where the pattern after = is optional and cannot contain ;
+func envExtraFields(key string) (fields []form.ExtraField) {
+	for _, entry := range strings.Split(os.Getenv(key), ";") {
+		parts := strings.SplitN(entry, "=", 2)
+		field := form.ExtraField{Name: strings.TrimSpace(parts[0])}
+		if field.Name == "" {
+			continue
+		}
+
+		if len(parts) == 2 && parts[1] != "" {
+			pattern, err := regexp.Compile(parts[1])
+			if err != nil {
+				log.WithFields(log.Fields(map[string]interface{}{
+					"key":   key,
+					"field": field.Name,
+					"error": err,
+				})).Fatal("Invalid pattern in environment")
 			}
+			field.Pattern = pattern
 		}
-	})
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		log.WithField("method", r.Method).Info("/health")
+		fields = append(fields, field)
+	}
+
+	return
+}
+
+// envLocation loads a time zone such as "Europe/Amsterdam" from the environment, falling back to UTC
+// when the time zone database does not know it
+func envLocation(key string, def string) *time.Location {
+	name := os.Getenv(key)
+	if name == "" {
+		name = def
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"key":   key,
+			"value": name,
+			"error": err,
+		})).Warn("Unknown time zone in environment, using UTC")
+		return time.UTC
+	}
 
-		if _, err := w.Write([]byte("OK")); err != nil {
-			log.WithField("error", err).Error("Failed to handle health request")
-			http.Error(w, "Could not return health OK", http.StatusInternalServerError)
+	return location
+}
+
+// envBool reads a boolean such as "true" or "1" from the environment, falling back to def when unset or invalid
+func envBool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"key":   key,
+			"value": value,
+		})).Warn("Invalid boolean in environment, using default")
+		return def
+	}
+
+	return parsed
+}
+
+// envDuration reads a duration such as "30m" from the environment, falling back to def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.WithFields(log.Fields(map[string]interface{}{
+			"key":   key,
+			"value": value,
+		})).Warn("Invalid duration in environment, using default")
+		return def
+	}
+
+	return parsed
+}
+
+// pool limits the connections to the database
+type pool struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+// poolConfig reads the limits of the connection pool from the environment, small managed
+// databases only allow a handful of connections
+func poolConfig() pool {
+	return pool{
+		maxOpen:     int(envInt("DB_MAX_OPEN_CONNS", 10)),
+		maxIdle:     int(envInt("DB_MAX_IDLE_CONNS", 5)),
+		maxLifetime: envDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+	}
+}
+
+// apply sets the limits on the connection pool of db
+func (p pool) apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.maxOpen)
+	db.SetMaxIdleConns(p.maxIdle)
+	db.SetConnMaxLifetime(p.maxLifetime)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	buffer, err := json.Marshal(v)
+	if err != nil {
+		log.WithField("error", err).Error("Failed to encode response")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("content-type", jsonContentType)
+	w.WriteHeader(status)
+	w.Write(buffer)
+}
+
+// acceptedContentType reports whether the media type of contentType, ignoring parameters such as
+// the charset, is one of accepted
+func acceptedContentType(contentType string, accepted []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, a := range accepted {
+		if strings.EqualFold(mediaType, a) {
+			return true
 		}
-	})
+	}
 
-	ticker := time.NewTicker(10 * time.Minute)
-	go func() {
-		baseURL := os.Getenv("BASE_URL")
-		for range ticker.C {
-			http.Get(fmt.Sprintf("%s/%s", baseURL, "health"))
+	return false
+}
+
+// webhookSecrets reads the comma separated WEBHOOK_SECRETS, accepting several secrets while
+// rotating them, and falls back to the single WEBHOOK_SECRET
+func webhookSecrets() (secrets []string) {
+	if secrets = envList("WEBHOOK_SECRETS"); len(secrets) == 0 {
+		secrets = []string{os.Getenv("WEBHOOK_SECRET")}
+	}
+
+	return
+}
+
+// validSecret compares in constant time to not leak the secrets through response timing
+func validSecret(given string, secrets []string) bool {
+	valid := false
+	for _, secret := range secrets {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1 {
+			valid = true
 		}
-	}()
+	}
+
+	return valid
+}
+
+// sqliteDriver tells whether DB_DRIVER selects SQLite, which is only meant for local development
+func sqliteDriver() bool {
+	return os.Getenv("DB_DRIVER") == "sqlite3"
+}
+
+// newStore creates the store of the database selected by DB_DRIVER
+func newStore(db *sql.DB, location *time.Location) form.Store {
+	if sqliteDriver() {
+		return form.NewSQLiteStore(db, location)
+	}
+
+	return form.NewSQLStore(db, location)
+}
+
+// openDB connects to DATABASE_URL, using the tables of schema unless it is empty
+func openDB(schema string) (db *sql.DB, err error) {
+	driver := os.Getenv("DB_DRIVER")
+	if driver == "" {
+		driver = "postgres"
+	}
 
-	http.ListenAndServe(fmt.Sprintf(":%s", os.Getenv("PORT")), nil)
+	dataSource := os.Getenv("DATABASE_URL")
+	if schema != "" {
+		dataSource = withSearchPath(dataSource, schema)
+	}
+
+	if db, err = sql.Open(driver, dataSource); err != nil {
+		return
+	}
+
+	poolConfig().apply(db)
+
+	// SQLite allows a single writer, with one connection the others wait instead of failing; the
+	// tables of a new development database are created right away
+	if sqliteDriver() {
+		db.SetMaxOpenConns(1)
+		err = form.CreateSQLiteSchema(context.Background(), db)
+	}
+
+	return
+}
+
+func handlerConfig() form.Config {
+	return form.Config{
+		IgnoredAlertThreshold: envInt("IGNORED_ALERT_THRESHOLD", 0),
+		Fields: form.FieldMapping{
+			Club:             os.Getenv("FIELD_CLUB"),
+			Name:             os.Getenv("FIELD_NAME"),
+			Surname:          os.Getenv("FIELD_SURNAME"),
+			Email:            os.Getenv("FIELD_EMAIL"),
+			Phone:            os.Getenv("FIELD_PHONE"),
+			FullName:         os.Getenv("FIELD_FULL_NAME"),
+			Notes:            os.Getenv("FIELD_NOTES"),
+			Consent:          os.Getenv("FIELD_CONSENT"),
+			TeamName:         os.Getenv("FIELD_TEAM_NAME"),
+			TeamType:         os.Getenv("FIELD_TEAM_TYPE"),
+			TeamLevel:        os.Getenv("FIELD_TEAM_LEVEL"),
+			TeamPoule:        os.Getenv("FIELD_TEAM_POULE"),
+			TeamAvailability: os.Getenv("FIELD_TEAM_AVAILABILITY"),
+			Reference:        os.Getenv("FIELD_REFERENCE"),
+			SecondName:       os.Getenv("FIELD_SECOND_NAME"),
+			SecondSurname:    os.Getenv("FIELD_SECOND_SURNAME"),
+			SecondEmail:      os.Getenv("FIELD_SECOND_EMAIL"),
+			SecondPhone:      os.Getenv("FIELD_SECOND_PHONE"),
+		},
+		SeasonYear:              int(envInt("SEASON_YEAR", 0)),
+		SeasonStartMonth:        time.Month(envInt("SEASON_START_MONTH", 0)),
+		StrictSeason:            envBool("STRICT_SEASON", features.StrictSeason),
+		IDStrategy:              os.Getenv("ID_STRATEGY"),
+		IDWidth:                 int(envInt("ID_WIDTH", 0)),
+		IDNoLeadingZero:         envBool("ID_NO_LEADING_ZERO", false),
+		IDPrefix:                os.Getenv("ID_PREFIX"),
+		IDLoading:               os.Getenv("ID_LOADING"),
+		Clubs:                   envList("CLUBS"),
+		ClubMaxDistance:         int(envInt("CLUB_MAX_DISTANCE", 0)),
+		DuplicateWindow:         envDuration("DUPLICATE_WINDOW", 0),
+		DuplicateMode:           os.Getenv("DUPLICATE_MODE"),
+		MinSubmitInterval:       envDuration("MIN_SUBMIT_INTERVAL", 0),
+		AllowedEmailDomains:     envList("ALLOWED_EMAIL_DOMAINS"),
+		MaxTeams:                int(envInt("MAX_TEAMS", 0)),
+		HoneypotField:           os.Getenv("HONEYPOT_FIELD"),
+		Poules:                  envPoules("POULES"),
+		StrictPoules:            envBool("STRICT_POULES", features.StrictPoules),
+		RegionalLevels:          envPoules("REGIONAL_LEVELS"),
+		Days:                    envList("AVAILABILITY_DAYS"),
+		LevelCodes:              envNumbers("LEVEL_CODES"),
+		StrictLevelCodes:        envBool("STRICT_LEVEL_CODES", false),
+		StrictAvailability:      envBool("STRICT_AVAILABILITY", features.StrictAvailability),
+		StrictTeamSlots:         envBool("STRICT_TEAM_SLOTS", features.StrictTeamSlots),
+		SortTeams:               envBool("SORT_TEAMS", features.SortTeams),
+		ExactTitles:             envBool("EXACT_TITLES", false),
+		ContiguousTeams:         envBool("CONTIGUOUS_TEAMS", features.ContiguousTeams),
+		UniqueTeamNames:         envBool("UNIQUE_TEAM_NAMES", features.UniqueTeamNames),
+		Unknown:                 os.Getenv("UNKNOWN_VALUE"),
+		TypePatterns:            envValuePatterns("TYPE_PATTERNS"),
+		LevelPatterns:           envValuePatterns("LEVEL_PATTERNS"),
+		DutchValidation:         os.Getenv("DUTCH_VALIDATION"),
+		QueueSize:               int(envInt("QUEUE_SIZE", 0)),
+		QueueRetries:            int(envInt("QUEUE_RETRIES", 0)),
+		QueueBackoff:            envDuration("QUEUE_BACKOFF", 0),
+		Location:                envLocation("TIMEZONE", "Europe/Amsterdam"),
+		RequiredTypes:           envList("REQUIRED_TYPES"),
+		MaxTeamsPerType:         envNumbers("MAX_TEAMS_PER_TYPE"),
+		StoreRetries:            int(envInt("STORE_RETRIES", 0)),
+		MaxRegistrations:        int(envInt("MAX_REGISTRATIONS_PER_SEASON", 0)),
+		Blocklist:               loadBlocklist(os.Getenv("BLOCKLIST_FILE")),
+		CallbackURL:             os.Getenv("CALLBACK_URL"),
+		AuditSize:               int(envInt("AUDIT_SIZE", 0)),
+		StoreSource:             envBool("STORE_SOURCE", false),
+		HTTPClient:              httpClient,
+		ExtraFields:             envExtraFields("EXTRA_FIELDS"),
+		ConsentVersion:          os.Getenv("CONSENT_VERSION"),
+		PhoneRegion:             os.Getenv("DEFAULT_PHONE_REGION"),
+		PhoneRegionFromLanguage: envBool("PHONE_REGION_FROM_LANGUAGE", false),
+	}
 }
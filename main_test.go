@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadBlocklist(t *testing.T) {
+	file, dir := writeTemp(t, "# banned after the 2017 season\nspam@example.com\n\n  Banned club  \n")
+	defer os.RemoveAll(dir)
+
+	expected := []string{"spam@example.com", "Banned club"}
+	if blocklist := loadBlocklist(file); !reflect.DeepEqual(blocklist, expected) {
+		t.Errorf("expected %q, got %q", expected, blocklist)
+	}
+
+	if blocklist := loadBlocklist(""); blocklist != nil {
+		t.Errorf("expected no blocklist without a file, got %q", blocklist)
+	}
+}
+
+func TestEnvNumbers(t *testing.T) {
+	os.Setenv("TEST_LEVEL_CODES", "Bond 2:20; Regio 1 : 31;invalid")
+	defer os.Unsetenv("TEST_LEVEL_CODES")
+
+	expected := map[string]int{"Bond 2": 20, "Regio 1": 31}
+	if codes := envNumbers("TEST_LEVEL_CODES"); !reflect.DeepEqual(codes, expected) {
+		t.Errorf("expected %v, got %v", expected, codes)
+	}
+}
+
+func TestEnvValuePatterns(t *testing.T) {
+	os.Setenv("TEST_TYPE_PATTERNS", `^Men\b.*=Heren; (?i)^wo=men = Dames ;no separator`)
+	defer os.Unsetenv("TEST_TYPE_PATTERNS")
+
+	patterns := envValuePatterns("TEST_TYPE_PATTERNS")
+	if len(patterns) != 2 {
+		t.Fatalf("expected 2 patterns, got %d", len(patterns))
+	}
+
+	// the value follows the last =, so a pattern may contain one
+	expected := []struct{ pattern, value string }{{`^Men\b.*`, "Heren"}, {`(?i)^wo=men`, "Dames"}}
+	for i, pattern := range patterns {
+		if pattern.Pattern.String() != expected[i].pattern || pattern.Value != expected[i].value {
+			t.Errorf("expected %s=%s, got %s=%s", expected[i].pattern, expected[i].value, pattern.Pattern, pattern.Value)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maintenance pauses the intake of submissions while it is on, see MAINTENANCE_MODE
+type maintenance struct {
+	on int32
+}
+
+func (m *maintenance) enabled() bool {
+	return atomic.LoadInt32(&m.on) == 1
+}
+
+func (m *maintenance) set(enabled bool) {
+	var on int32
+	if enabled {
+		on = 1
+	}
+	atomic.StoreInt32(&m.on, on)
+}
+
+// paused answers 503 while in maintenance, wordpress retries the submission later
+func (m *maintenance) paused(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.enabled() {
+			log.WithField("path", r.URL.Path).Warn("Refusing request during maintenance")
+			w.Header().Set("Retry-After", "300")
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+type maintenanceResponse struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// maintenanceHandler shows the maintenance mode and switches it with POST ?enabled=true or false
+func maintenanceHandler(m *maintenance, adminTokens []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if !checkAdminToken(w, r, adminTokens) {
+				return
+			}
+
+			enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+			if err != nil {
+				http.Error(w, "Invalid enabled", http.StatusBadRequest)
+				return
+			}
+
+			m.set(enabled)
+			log.WithField("enabled", enabled).Warn("Switched maintenance mode")
+		default:
+			methodNotAllowed(w, r, http.MethodGet+", "+http.MethodPost)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, maintenanceResponse{m.enabled()})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestMaintenancePausesHook(t *testing.T) {
+	hook, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	pause := &maintenance{}
+	pause.set(true)
+
+	rec := post(pause.paused(hook), validBody)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 503 with Retry-After during maintenance, got %d", rec.Code)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected nothing to be stored during maintenance, got %d registrations", store.Registrations())
+	}
+
+	pause.set(false)
+
+	if rec = post(pause.paused(hook), validBody); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 after maintenance, got %d: %s", rec.Code, rec.Body)
+	}
+	if store.Registrations() != 1 {
+		t.Errorf("expected the submission to be stored after maintenance, got %d registrations", store.Registrations())
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	pause := &maintenance{}
+	handler := maintenanceHandler(pause, []string{"admin"})
+
+	if rec := request(handler, http.MethodPost, "/maintenance?enabled=true", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without admin token, got %d", rec.Code)
+	}
+	if pause.enabled() {
+		t.Fatal("expected maintenance to stay off without admin token")
+	}
+
+	rec := request(handler, http.MethodPost, "/maintenance?enabled=true", "", "X-admin-token", "admin")
+	if rec.Code != http.StatusOK || !pause.enabled() {
+		t.Errorf("expected maintenance to be switched on, got %d", rec.Code)
+	}
+
+	if rec = request(handler, http.MethodPost, "/maintenance?enabled=maybe", "", "X-admin-token", "admin"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid value, got %d", rec.Code)
+	}
+
+	if rec = request(handler, http.MethodGet, "/maintenance", ""); rec.Code != http.StatusOK || rec.Body.String() != `{"maintenance":true}` {
+		t.Errorf("expected the maintenance mode to be shown, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = request(handler, http.MethodDelete, "/maintenance", "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("expected 405 allowing GET and POST, got %d with %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// metricsHandler renders the expvar variables in the Prometheus text format. A number is a counter,
+// the numbers in a map, such as db_pool, are gauges named after the map and their key. Other
+// variables, such as memstats, are left out.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		switch v := kv.Value.(type) {
+		case *expvar.Int:
+			writeMetric(w, "counter", kv.Key, v.String())
+		case *expvar.Map:
+			v.Do(func(entry expvar.KeyValue) {
+				switch entry.Value.(type) {
+				case *expvar.Int, *expvar.Float:
+					writeMetric(w, "gauge", kv.Key+"_"+entry.Key, entry.Value.String())
+				}
+			})
+		}
+	})
+}
+
+func writeMetric(w http.ResponseWriter, kind string, name string, value string) {
+	name = metricName(name)
+	fmt.Fprintf(w, "# TYPE %s %s\n%s %s\n", name, kind, name, value)
+}
+
+// metricName replaces the characters Prometheus does not allow in a name by underscores
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
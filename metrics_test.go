@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHasPoolGauges(t *testing.T) {
+	for name, value := range poolGauges(sql.DBStats{OpenConnections: 3}) {
+		setGauge(name, value)
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected the text format, got %q", ct)
+	}
+
+	body := rec.Body.String()
+	expected := []string{
+		"# TYPE db_pool_open_connections gauge\n",
+		"db_pool_open_connections 3\n",
+		"# TYPE ignored_messages counter\n",
+	}
+	if poolWaitsKnown {
+		expected = append(expected,
+			"# TYPE db_pool_in_use gauge\n",
+			"# TYPE db_pool_idle gauge\n",
+			"# TYPE db_pool_wait_count gauge\n",
+			"# TYPE db_pool_wait_duration_ms gauge\n",
+		)
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in\n%s", line, body)
+		}
+	}
+
+	if strings.Contains(body, "memstats") {
+		t.Errorf("expected only counters and gauges, got\n%s", body)
+	}
+}
+
+func TestMetricsRequiresSecret(t *testing.T) {
+	handler := requireSecret([]string{"secret"}, metricsHandler)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the secret, got %d", rec.Code)
+	}
+}
+
+func TestMetricName(t *testing.T) {
+	if name := metricName("db_pool_in-use.x"); name != "db_pool_in_use_x" {
+		t.Errorf("expected db_pool_in_use_x, got %s", name)
+	}
+}
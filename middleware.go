@@ -0,0 +1,166 @@
+package main
+
+import (
+	"compress/gzip"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// trustProxy enables reading the client IP from the headers of the load balancer, see TRUST_PROXY
+var trustProxy bool
+
+// authFailureDelay is the minimum response time of a rejected secret and authFailureJitter the
+// random time added to it, see AUTH_FAILURE_DELAY and AUTH_FAILURE_JITTER
+var authFailureDelay, authFailureJitter time.Duration
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// recoverPanics turns a panic in next into a logged 500 response instead of a dropped connection
+func recoverPanics(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.WithFields(log.Fields(map[string]interface{}{
+					"panic": p,
+					"path":  r.URL.Path,
+					"ip":    clientIP(r),
+					"stack": string(debug.Stack()),
+				})).Error("Recovered from panic")
+
+				writeJSON(w, http.StatusInternalServerError, errorResponse{"Internal Server Error"})
+			}
+		}()
+
+		next(w, r)
+	}
+}
+
+// requireSecret only passes requests carrying one of the webhook secrets on to next
+func requireSecret(secrets []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		if !validSecret(r.Header.Get("X-hook-secret"), secrets) {
+			slowDown(started)
+			// the submitted value is not logged, it may be a retired or mistyped secret
+			log.WithFields(log.Fields(map[string]interface{}{
+				"path": r.URL.Path,
+				"ip":   clientIP(r),
+			})).Error("Invalid secret")
+			http.Error(w, "Invalid Secret", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// checkAdminToken responds with 403 unless the request carries one of the admin tokens, without
+// configured tokens every request is refused
+func checkAdminToken(w http.ResponseWriter, r *http.Request, adminTokens []string) bool {
+	started := time.Now()
+
+	// an empty header never matches since envList skips empty tokens
+	if len(adminTokens) == 0 || !validSecret(r.Header.Get("X-admin-token"), adminTokens) {
+		slowDown(started)
+		log.WithFields(log.Fields(map[string]interface{}{
+			"path": r.URL.Path,
+			"ip":   clientIP(r),
+		})).Error("Invalid admin token")
+		http.Error(w, "Invalid Admin Token", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// slowDown delays a rejected request until authFailureDelay after started plus some jitter, so the
+// response time does not tell how far the request got
+func slowDown(started time.Time) {
+	wait := authFailureDelay - time.Since(started)
+	if authFailureJitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(authFailureJitter)))
+	}
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// methodNotAllowed rejects a request with an unsupported method, these are usually harmless probes
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"ip":     clientIP(r),
+	})).Warn("Invalid method")
+
+	w.Header().Set("Allow", allowed)
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (g gzipResponseWriter) Write(b []byte) (int, error) {
+	return g.gz.Write(b)
+}
+
+// compress gzips the response of next when the client accepts it, meant for endpoints with large responses
+func compress(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+
+		next(gzipResponseWriter{w, gz}, r)
+	}
+}
+
+// requestSource describes the client of a request by its User-Agent, followed by its Referer if it
+// has one, such as "WordPress/6.4; https://sbc2000.nl | https://sbc2000.nl/inschrijven/"
+func requestSource(r *http.Request) string {
+	source := r.UserAgent()
+	if referer := r.Referer(); referer != "" {
+		source += " | " + referer
+	}
+
+	return source
+}
+
+// clientIP returns the IP of the client, behind a trusted proxy this is the last address it added
+// to X-Forwarded-For since anything before it is supplied by the client and can be spoofed
+func clientIP(r *http.Request) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addresses := strings.Split(forwarded, ",")
+			return strings.TrimSpace(addresses[len(addresses)-1])
+		}
+
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
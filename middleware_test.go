@@ -0,0 +1,249 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", recoverPanics(func(w http.ResponseWriter, r *http.Request) {
+		var teams map[string]int
+		teams["Heren 1"]++
+	}))
+	mux.HandleFunc("/health", recoverPanics(healthHandler))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/panic")
+	if err != nil {
+		t.Fatalf("expected a response rather than a dropped connection: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", resp.StatusCode)
+	}
+	var body errorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Error == "" {
+		t.Errorf("expected a JSON error, got %v", err)
+	}
+
+	// the server keeps serving after the panic
+	health, err := http.Get(server.URL + "/health")
+	if err != nil {
+		t.Fatalf("expected the server to keep serving: %v", err)
+	}
+	health.Body.Close()
+	if health.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", health.StatusCode)
+	}
+}
+
+func TestRequireSecretAcceptsEverySecret(t *testing.T) {
+	handler := requireSecret([]string{"old", "new"}, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		secret string
+		status int
+	}{
+		{"old", http.StatusNoContent},
+		{"new", http.StatusNoContent},
+		{"other", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+		r.Header.Set("X-hook-secret", test.secret)
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+
+		if rec.Code != test.status {
+			t.Errorf("secret %q: expected %d, got %d", test.secret, test.status, rec.Code)
+		}
+	}
+}
+
+// logCapture keeps the entries logged since it was last reset
+type logCapture struct {
+	mu      sync.Mutex
+	entries []*log.Entry
+}
+
+var (
+	captured    = &logCapture{}
+	captureOnce sync.Once
+)
+
+func (c *logCapture) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (c *logCapture) Fire(entry *log.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = append(c.entries, entry)
+	return nil
+}
+
+// capture starts capturing the log entries, forgetting those captured before
+func capture() *logCapture {
+	captureOnce.Do(func() { log.AddHook(captured) })
+
+	captured.mu.Lock()
+	defer captured.mu.Unlock()
+
+	captured.entries = nil
+	return captured
+}
+
+// contains reports whether a captured entry has value in its message or fields
+func (c *logCapture) contains(value string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.entries {
+		if strings.Contains(entry.Message, value) || strings.Contains(fmt.Sprint(entry.Data), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRequireSecretDoesNotLogSecret(t *testing.T) {
+	logs := capture()
+
+	handler := requireSecret([]string{testSecret}, func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+	r.Header.Set("X-hook-secret", "retired-secret")
+	handler(httptest.NewRecorder(), r)
+
+	if !logs.contains("Invalid secret") {
+		t.Error("expected the rejected request to be logged")
+	}
+	if logs.contains("retired-secret") {
+		t.Error("expected the submitted secret not to be logged")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	const csv = "vereniging,teams\nKinheim,1\nSBC2000,5\n"
+	handler := compress(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte(csv))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/clubs", nil)
+	r.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected a gzip response, got %q", encoding)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("expected a gzip body: %v", err)
+	}
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("expected a gzip body: %v", err)
+	}
+	if string(body) != csv {
+		t.Errorf("expected %q, got %q", csv, body)
+	}
+
+	// without gzip in Accept-Encoding the response is sent as is
+	r.Header.Del("Accept-Encoding")
+	rec = httptest.NewRecorder()
+	handler(rec, r)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != csv {
+		t.Errorf("expected an uncompressed response, got %q", rec.Body.String())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(trusted bool) { trustProxy = trusted }(trustProxy)
+
+	tests := []struct {
+		trusted   bool
+		forwarded string
+		realIP    string
+		expected  string
+	}{
+		{false, "", "", "10.0.0.1"},
+		// without a trusted proxy the headers are supplied by the client
+		{false, "203.0.113.7", "203.0.113.8", "10.0.0.1"},
+		{true, "203.0.113.7", "", "203.0.113.7"},
+		// the client can prepend any address, the proxy appends the one it saw
+		{true, "198.51.100.1, 203.0.113.7", "", "203.0.113.7"},
+		{true, "", "203.0.113.8", "203.0.113.8"},
+		{true, "", "", "10.0.0.1"},
+	}
+
+	for _, test := range tests {
+		trustProxy = test.trusted
+
+		r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+		r.RemoteAddr = "10.0.0.1:51234"
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if test.realIP != "" {
+			r.Header.Set("X-Real-IP", test.realIP)
+		}
+
+		if ip := clientIP(r); ip != test.expected {
+			t.Errorf("trusted %t, forwarded %q, real IP %q: expected %s, got %s",
+				test.trusted, test.forwarded, test.realIP, test.expected, ip)
+		}
+	}
+}
+
+func TestAuthFailureDelay(t *testing.T) {
+	defer func(delay, jitter time.Duration) {
+		authFailureDelay, authFailureJitter = delay, jitter
+	}(authFailureDelay, authFailureJitter)
+	authFailureDelay, authFailureJitter = 50*time.Millisecond, 10*time.Millisecond
+
+	handler := requireSecret([]string{testSecret}, func(w http.ResponseWriter, r *http.Request) {})
+	send := func(secret string) (int, time.Duration) {
+		r := httptest.NewRequest(http.MethodPost, "/hook", nil)
+		r.Header.Set("X-hook-secret", secret)
+		rec := httptest.NewRecorder()
+
+		started := time.Now()
+		handler(rec, r)
+		return rec.Code, time.Since(started)
+	}
+
+	for i := 0; i < 3; i++ {
+		status, took := send("wrong")
+		if status != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", status)
+		}
+		// generous upper bound, a loaded machine may oversleep
+		if took < authFailureDelay || took > time.Second {
+			t.Errorf("expected the rejection to take about %v, took %v", authFailureDelay, took)
+		}
+	}
+
+	if status, took := send(testSecret); status != http.StatusOK || took >= authFailureDelay {
+		t.Errorf("expected an accepted secret not to be delayed, got %d after %v", status, took)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// multipartForm reports whether a body of contentType is a form posted as multipart/form-data
+func multipartForm(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// decodeMultipart reads a form posted as multipart/form-data into a message, the title field
+// becomes the title and every other field the posted data. Uploaded files are skipped.
+func decodeMultipart(r *http.Request, body io.Reader) (msg form.Message, err error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return msg, form.ValidationErrors{"invalid message: multipart body without boundary"}
+	}
+
+	// the body is read part by part, so the size cap applies just like for JSON
+	reader := multipart.NewReader(body, params["boundary"])
+	values := make(map[string][]string)
+	for {
+		part, partErr := reader.NextPart()
+		if partErr == io.EOF {
+			break
+		}
+		if partErr != nil {
+			return msg, form.ValidationErrors{"invalid message: " + partErr.Error()}
+		}
+
+		if part.FormName() == "" || part.FileName() != "" {
+			part.Close()
+			continue
+		}
+
+		var value strings.Builder
+		if _, partErr = io.Copy(&value, part); partErr != nil {
+			return msg, form.ValidationErrors{"invalid message: " + partErr.Error()}
+		}
+		values[part.FormName()] = append(values[part.FormName()], value.String())
+	}
+
+	msg.Data = make(map[string]string, len(values))
+	for name, list := range values {
+		if name == "title" {
+			msg.Title = list[0]
+			continue
+		}
+		// checkbox fields post every checked option, like a list in JSON
+		msg.Data[name] = strings.Join(list, ", ")
+	}
+
+	if msg.Title == "" {
+		return msg, form.ValidationErrors{"title is required"}
+	}
+
+	return
+}
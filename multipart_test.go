@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestHookAcceptsMultipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, field := range [][2]string{
+		{"title", "Inschrijven teams"},
+		{"contact-club", "SBC2000"},
+		{"contact-name", "Jan"},
+		{"contact-surname", "Jansen"},
+		{"contact-email", "jan@example.com"},
+		{"contact-phone", "0612345678"},
+		{"team1-name", "Heren 1"},
+		{"team1-type", "Heren"},
+		{"team1-level", "Regio 1"},
+	} {
+		writer.WriteField(field[0], field[1])
+	}
+	// uploaded files are not part of the message
+	file, _ := writer.CreateFormFile("team1-name", "logo.png")
+	file.Write([]byte("not a team name"))
+	writer.Close()
+
+	stored := func(body string, contentType string) form.Registration {
+		hook, formHandler, store := newTestHook(t, form.Config{})
+		defer formHandler.Close()
+
+		rec := post(hook, body, "Content-Type", contentType)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", contentType, rec.Code, rec.Body)
+		}
+
+		var result form.Result
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: expected a JSON response: %v", contentType, err)
+		}
+
+		registration, _, err := store.Registration(context.Background(), result.SubscriptionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return registration
+	}
+
+	fromJSON := stored(validBody, "application/json")
+	fromMultipart := stored(body.String(), writer.FormDataContentType())
+	if !reflect.DeepEqual(fromJSON, fromMultipart) {
+		t.Errorf("expected the same registration as from JSON\n%+v, got\n%+v", fromJSON, fromMultipart)
+	}
+}
+
+func TestHookRejectsMultipartWithoutBoundary(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	if rec := post(hook, "title=Inschrijven+teams", "Content-Type", "multipart/form-data"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a boundary, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// respond writes v as JSON, or as a plain text summary when the client prefers text/plain and v
+// has one
+func respond(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	if text, ok := summary(v); ok && wantsText(r.Header.Get("Accept")) {
+		w.Header().Set("content-type", textContentType)
+		w.WriteHeader(status)
+		w.Write([]byte(text))
+		return
+	}
+
+	writeJSON(w, status, v)
+}
+
+// wantsText reports whether text/plain comes before any JSON type in accept, quality values are
+// not taken into account
+func wantsText(accept string) bool {
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(entry)
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case "text/plain":
+			return true
+		case "application/json", "application/*", "*/*":
+			return false
+		}
+	}
+
+	return false
+}
+
+// summary describes the responses that have a plain text form
+func summary(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case form.Result:
+		text := fmt.Sprintf("Subscription %s with %d teams\n", v.SubscriptionID, v.Teams)
+		if v.Queued {
+			text = "Submission queued\n"
+		} else if v.Message != "" {
+			text = v.Message + "\n"
+		}
+		for _, warning := range v.Warnings {
+			text += "Warning: " + warning + "\n"
+		}
+		return text, true
+	case page:
+		clubs, ok := v.Items.([]form.ClubSummary)
+		if !ok {
+			return "", false
+		}
+
+		var buffer bytes.Buffer
+		for _, club := range clubs {
+			fmt.Fprintf(&buffer, "%s\t%d\n", club.Club, club.Teams)
+		}
+		fmt.Fprintf(&buffer, "%d of %d clubs\n", len(clubs), v.Total)
+		return buffer.String(), true
+	}
+
+	return "", false
+}
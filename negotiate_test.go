@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestHookContentNegotiation(t *testing.T) {
+	tests := []struct {
+		accept string
+		text   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"text/plain", true},
+		{"text/plain;q=0.9, application/json", true},
+		{"application/json, text/plain", false},
+		{"text/html", false},
+	}
+
+	for _, test := range tests {
+		hook, formHandler, _ := newTestHook(t, form.Config{})
+		rec := post(hook, validBody, "Accept", test.accept)
+		formHandler.Close()
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", test.accept, rec.Code, rec.Body)
+		}
+
+		contentType := rec.Header().Get("Content-Type")
+		if test.text {
+			if !strings.HasPrefix(contentType, "text/plain") || !strings.HasPrefix(rec.Body.String(), "Bedankt!") {
+				t.Errorf("%q: expected a plain text summary, got %s %q", test.accept, contentType, rec.Body)
+			}
+			continue
+		}
+
+		var result form.Result
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.SubscriptionID == "" {
+			t.Errorf("%q: expected the JSON result, got %s %q", test.accept, contentType, rec.Body)
+		}
+	}
+}
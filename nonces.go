@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// nonceGuard rejects requests with an old X-timestamp or a repeated X-nonce, see REPLAY_WINDOW.
+// The headers are not signed, so this stops replays of captured requests only together with the secret.
+type nonceGuard struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]time.Time
+}
+
+func newNonceGuard(window time.Duration) *nonceGuard {
+	return &nonceGuard{window: window, seen: make(map[string]time.Time)}
+}
+
+// check responds with 403 and returns false when the request is stale or replayed
+func (g *nonceGuard) check(w http.ResponseWriter, r *http.Request) bool {
+	if g.window == 0 {
+		return true
+	}
+
+	now := time.Now()
+	seconds, err := strconv.ParseInt(r.Header.Get("X-timestamp"), 10, 64)
+	if err != nil {
+		return g.reject(w, r, "Invalid timestamp")
+	}
+
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > g.window || skew < -g.window {
+		return g.reject(w, r, "Stale timestamp")
+	}
+
+	nonce := r.Header.Get("X-nonce")
+	if nonce == "" {
+		return g.reject(w, r, "Missing nonce")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// a nonce outside the window would already fail on its timestamp, so it can be forgotten
+	for n, at := range g.seen {
+		if now.Sub(at) > 2*g.window {
+			delete(g.seen, n)
+		}
+	}
+
+	if _, ok := g.seen[nonce]; ok {
+		return g.reject(w, r, "Replayed nonce")
+	}
+	g.seen[nonce] = now
+
+	return true
+}
+
+func (g *nonceGuard) reject(w http.ResponseWriter, r *http.Request, reason string) bool {
+	log.WithFields(log.Fields(map[string]interface{}{
+		"reason":    reason,
+		"timestamp": r.Header.Get("X-timestamp"),
+		"nonce":     r.Header.Get("X-nonce"),
+		"ip":        clientIP(r),
+	})).Error("Rejecting possible replay")
+	http.Error(w, reason, http.StatusForbidden)
+
+	return false
+}
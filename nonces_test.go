@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestReplayProtection(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	settings := testSettings(formHandler)
+	settings.nonces = newNonceGuard(time.Minute)
+	hook := hookHandler(settings)
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		status    int
+	}{
+		{"fresh", now, "a1", http.StatusOK},
+		{"replayed nonce", now, "a1", http.StatusForbidden},
+		{"stale timestamp", stale, "a2", http.StatusForbidden},
+		{"missing timestamp", "", "a3", http.StatusForbidden},
+		{"missing nonce", now, "", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		if rec := post(hook, validBody, "X-timestamp", test.timestamp, "X-nonce", test.nonce); rec.Code != test.status {
+			t.Errorf("%s: expected %d, got %d", test.name, test.status, rec.Code)
+		}
+	}
+
+	if store.Registrations() != 1 {
+		t.Errorf("expected only the fresh request to be stored, got %d registrations", store.Registrations())
+	}
+}
+
+func TestReplayProtectionDisabled(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Errorf("expected requests without timestamp and nonce to be accepted, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"database/sql"
+	"expvar"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// poolStats publishes the connection pool of the database with the other expvar counters
+var poolStats = expvar.NewMap("db_pool")
+
+// watchPool updates poolStats every interval. When more than waitAlert requests had to wait for a
+// connection during an interval an error is logged, since the pool is then too small; 0 disables
+// the alert.
+func watchPool(db *sql.DB, interval time.Duration, waitAlert int64) {
+	if waitAlert > 0 && !poolWaitsKnown {
+		log.Warn("DB_WAIT_ALERT_THRESHOLD needs a build with Go 1.11 or later, the alert is disabled")
+	}
+
+	var waited int64
+	for range time.Tick(interval) {
+		gauges := poolGauges(db.Stats())
+		for name, value := range gauges {
+			setGauge(name, value)
+		}
+
+		waits, ok := gauges["wait_count"]
+		if !ok {
+			continue
+		}
+
+		if waitAlert > 0 && waits-waited > waitAlert {
+			log.WithFields(log.Fields(map[string]interface{}{
+				"waits":   waits - waited,
+				"inUse":   gauges["in_use"],
+				"maxOpen": gauges["max_open_connections"],
+			})).Error("Database connection pool is saturated, consider raising DB_MAX_OPEN_CONNS")
+		}
+		waited = waits
+	}
+}
+
+func setGauge(name string, value int64) {
+	gauge := new(expvar.Int)
+	gauge.Set(value)
+	poolStats.Set(name, gauge)
+}
@@ -0,0 +1,17 @@
+//go:build !go1.11
+// +build !go1.11
+
+package main
+
+import "database/sql"
+
+// poolWaitsKnown tells whether the stats count the requests waiting for a connection, before
+// Go 1.11 they only have the number of open connections
+const poolWaitsKnown = false
+
+// poolGauges converts the stats of the connection pool to gauges
+func poolGauges(stats sql.DBStats) map[string]int64 {
+	return map[string]int64{
+		"open_connections": int64(stats.OpenConnections),
+	}
+}
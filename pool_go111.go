@@ -0,0 +1,24 @@
+//go:build go1.11
+// +build go1.11
+
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+// poolWaitsKnown tells whether the stats count the requests waiting for a connection
+const poolWaitsKnown = true
+
+// poolGauges converts the stats of the connection pool to gauges
+func poolGauges(stats sql.DBStats) map[string]int64 {
+	return map[string]int64{
+		"max_open_connections": int64(stats.MaxOpenConnections),
+		"open_connections":     int64(stats.OpenConnections),
+		"in_use":               int64(stats.InUse),
+		"idle":                 int64(stats.Idle),
+		"wait_count":           stats.WaitCount,
+		"wait_duration_ms":     int64(stats.WaitDuration / time.Millisecond),
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPoolConfig(t *testing.T) {
+	if p := poolConfig(); p != (pool{maxOpen: 10, maxIdle: 5, maxLifetime: 30 * time.Minute}) {
+		t.Errorf("expected the default limits, got %+v", p)
+	}
+
+	os.Setenv("DB_MAX_OPEN_CONNS", "3")
+	os.Setenv("DB_MAX_IDLE_CONNS", "1")
+	os.Setenv("DB_CONN_MAX_LIFETIME", "5m")
+	defer func() {
+		os.Unsetenv("DB_MAX_OPEN_CONNS")
+		os.Unsetenv("DB_MAX_IDLE_CONNS")
+		os.Unsetenv("DB_CONN_MAX_LIFETIME")
+	}()
+
+	if p := poolConfig(); p != (pool{maxOpen: 3, maxIdle: 1, maxLifetime: 5 * time.Minute}) {
+		t.Errorf("expected the limits of the environment, got %+v", p)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// redactHook masks the contact details of clubs in log entries, enabled with REDACT_PII
+type redactHook struct{}
+
+// payloadFields hold a complete submission and are left out entirely
+var payloadFields = []string{"body", "data", "form", "message"}
+
+var (
+	// emailPattern and phonePattern find contact details quoted in error texts, such as those of
+	// form.ValidationErrors
+	emailPattern = regexp.MustCompile(`[^\s@;:,]+@[^\s@;:,]+`)
+	phonePattern = regexp.MustCompile(`\+?[0-9][0-9 ()./-]{6,}[0-9]`)
+)
+
+func (redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (redactHook) Fire(entry *log.Entry) error {
+	for key, value := range entry.Data {
+		// validation errors quote the submitted email address and phone number
+		if err, ok := value.(error); ok {
+			entry.Data[key] = scrub(err.Error())
+			continue
+		}
+
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "email":
+			entry.Data[key] = maskEmail(text)
+		case "phone":
+			entry.Data[key] = maskTail(text, 2)
+		case "name", "surname":
+			entry.Data[key] = maskTail(text, 0)
+		case "error", "errors", "problems":
+			entry.Data[key] = scrub(text)
+		}
+	}
+
+	for _, key := range payloadFields {
+		if _, ok := entry.Data[key]; ok {
+			entry.Data[key] = "[redacted]"
+		}
+	}
+
+	return nil
+}
+
+// scrub masks the email addresses and phone numbers in text
+func scrub(text string) string {
+	text = emailPattern.ReplaceAllStringFunc(text, maskEmail)
+	return phonePattern.ReplaceAllStringFunc(text, func(phone string) string {
+		return maskTail(phone, 2)
+	})
+}
+
+// maskEmail keeps the first letter and the domain of an address, "jan@example.com" becomes "j***@example.com"
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return maskTail(email, 0)
+	}
+
+	return maskTail(email[:at], 0) + email[at:]
+}
+
+// maskTail keeps the first letter and the last keep characters of value
+func maskTail(value string, keep int) string {
+	runes := []rune(value)
+	if len(runes) <= keep+1 {
+		return strings.Repeat("*", len(runes))
+	}
+
+	return string(runes[:1]) + "***" + string(runes[len(runes)-keep:])
+}
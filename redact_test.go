@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+func TestRedactHookMasksFields(t *testing.T) {
+	entry := &log.Entry{Data: log.Fields{
+		"email":   "jan@example.com",
+		"phone":   "0612345678",
+		"name":    "Jan",
+		"surname": "Jansen",
+		"error":   errors.New("Invalid email address: jan@example"),
+		"message": form.Message{Title: "Inschrijven teams"},
+		"club":    "SBC2000",
+	}}
+	redactHook{}.Fire(entry)
+
+	expected := map[string]interface{}{
+		"email":   "j***@example.com",
+		"phone":   "0***78",
+		"name":    "J***",
+		"surname": "J***",
+		"error":   "Invalid email address: j***@example",
+		"message": "[redacted]",
+		"club":    "SBC2000",
+	}
+	for key, value := range expected {
+		if entry.Data[key] != value {
+			t.Errorf("expected %s to be %q, got %q", key, value, entry.Data[key])
+		}
+	}
+}
+
+func TestRedactedSubmission(t *testing.T) {
+	hook, formHandler, store := newTestHook(t, form.Config{AllowedEmailDomains: []string{"sbc2000.nl"}})
+	defer formHandler.Close()
+
+	// the rejection quotes the address
+	logs := capture()
+	if rec := post(hook, validBody); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	valid := strings.Replace(validBody, `"jan@example.com"`, `"jan@sbc2000.nl"`, 1)
+	if rec := post(hook, valid); rec.Code != http.StatusOK {
+		t.Fatalf("expected the submission to be processed, got %d", rec.Code)
+	}
+	if store.Registrations() != 1 {
+		t.Fatalf("expected the submission to be stored, got %d registrations", store.Registrations())
+	}
+
+	logs.mu.Lock()
+	for _, entry := range logs.entries {
+		redactHook{}.Fire(entry)
+	}
+	logs.mu.Unlock()
+
+	for _, pii := range []string{"jan@example.com", "jan@sbc2000.nl", "0612345678", "Jansen"} {
+		if logs.contains(pii) {
+			t.Errorf("expected %s to be masked", pii)
+		}
+	}
+	if !logs.contains("j***@example.com") {
+		t.Error("expected the masked email address in the log")
+	}
+}
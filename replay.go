@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io/ioutil"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// replay runs a captured webhook body through the form handler, e.g.
+//
+//	registration-handler replay --file payload.json --dry-run
+func replay(args []string) (err error) {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	file := flags.String("file", "", "file containing the webhook body")
+	dryRun := flags.Bool("dry-run", false, "log instead of writing to the database")
+	if err = flags.Parse(args); err != nil {
+		return
+	}
+
+	var buffer []byte
+	if buffer, err = ioutil.ReadFile(*file); err != nil {
+		return
+	}
+
+	var msg form.Message
+	if msg, err = form.DecodeMessage(bytes.NewReader(buffer), envBool("STRICT_FIELDS", features.StrictFields)); err != nil {
+		return
+	}
+
+	config := handlerConfig()
+	if *dryRun {
+		// wordpress must not hear of a subscription ID that was never stored
+		config.CallbackURL = ""
+	}
+
+	var store form.Store
+	if *dryRun {
+		store = form.NewDryRunStore()
+	} else {
+		db, err := openDB("")
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		store = newStore(db, config.Location)
+	}
+
+	var formHandler form.Handler
+	if formHandler, err = form.NewHandler(store, config); err != nil {
+		return
+	}
+
+	var result form.Result
+	result, err = formHandler.Handle(context.Background(), msg)
+	// in async mode the message is only queued, Close waits until it is stored
+	formHandler.Close()
+	if err != nil {
+		return
+	}
+
+	log.WithFields(log.Fields(map[string]interface{}{
+		"file":   *file,
+		"dryRun": *dryRun,
+		"result": result,
+	})).Info("Replayed message")
+
+	return
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// writeTemp writes body to a file in a new directory, the caller removes the directory
+func writeTemp(t *testing.T, body string) (file string, dir string) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file = filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(file, []byte(body), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return file, dir
+}
+
+func TestReplayDryRun(t *testing.T) {
+	file, dir := writeTemp(t, validBody)
+	defer os.RemoveAll(dir)
+
+	if err := replay([]string{"--file", file, "--dry-run"}); err != nil {
+		t.Errorf("expected the payload to replay, got %v", err)
+	}
+}
+
+func TestReplayFailures(t *testing.T) {
+	file, dir := writeTemp(t, `{"title": "Inschrijven teams", "posted_data": {}}`)
+	defer os.RemoveAll(dir)
+
+	if err := replay([]string{"--file", file, "--dry-run"}); err == nil {
+		t.Error("expected an empty submission to fail")
+	}
+
+	if err := replay([]string{"--file", filepath.Join(dir, "missing.json"), "--dry-run"}); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
+
+func TestReplayDryRunSendsNoCallback(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	os.Setenv("CALLBACK_URL", server.URL)
+	defer os.Unsetenv("CALLBACK_URL")
+
+	file, dir := writeTemp(t, validBody)
+	defer os.RemoveAll(dir)
+
+	if err := replay([]string{"--file", file, "--dry-run"}); err != nil {
+		t.Fatalf("expected the payload to replay, got %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("expected no callback for a dry run, got %d", calls)
+	}
+}
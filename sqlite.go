@@ -0,0 +1,7 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+// SQLite is only meant for local development, build with -tags sqlite and set DB_DRIVER=sqlite3
+import _ "github.com/mattn/go-sqlite3"
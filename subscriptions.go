@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// subscriptionsHandler routes the requests for a single subscription, /subscriptions/{id}/...
+// Deleting a subscription additionally requires one of the admin tokens, request bodies larger
+// than maxBodyBytes are rejected
+func subscriptionsHandler(formHandler form.Handler, adminTokens []string, maxBodyBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/subscriptions/"), "/"), "/")
+		if len(parts) == 1 && parts[0] != "" {
+			if r.Method != http.MethodDelete {
+				methodNotAllowed(w, r, http.MethodDelete)
+				return
+			}
+			deleteSubscription(w, r, formHandler, parts[0], adminTokens)
+			return
+		}
+
+		if len(parts) != 2 || parts[0] == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		subscriptionID := parts[0]
+		switch parts[1] {
+		case "teams":
+			if r.Method != http.MethodPost {
+				methodNotAllowed(w, r, http.MethodPost)
+				return
+			}
+			addTeam(w, r, formHandler, subscriptionID, maxBodyBytes)
+		case "regenerate-id":
+			if r.Method != http.MethodPost {
+				methodNotAllowed(w, r, http.MethodPost)
+				return
+			}
+			regenerateID(w, r, formHandler, subscriptionID)
+		case "confirmation":
+			if r.Method != http.MethodGet {
+				methodNotAllowed(w, r, http.MethodGet)
+				return
+			}
+			confirmation(w, r, formHandler, subscriptionID)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func addTeam(w http.ResponseWriter, r *http.Request, formHandler form.Handler, subscriptionID string, maxBodyBytes int64) {
+	defer r.Body.Close()
+	body := newCappedReader(r.Body, maxBodyBytes)
+	buffer, err := ioutil.ReadAll(body)
+	if body.exceeded() {
+		tooLarge(w, maxBodyBytes)
+		return
+	}
+	if err != nil {
+		log.WithField("error", err).Error("Cannot read body")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var team form.TeamRequest
+	if err = json.Unmarshal(buffer, &team); err != nil {
+		log.WithField("error", err).Error("Cannot parse body")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := formHandler.AddTeam(r.Context(), subscriptionID, team)
+	if problems, ok := err.(form.ValidationErrors); ok {
+		writeJSON(w, http.StatusBadRequest, validationResponse{problems})
+		return
+	}
+
+	switch err {
+	case nil:
+		respond(w, r, http.StatusOK, result)
+	case form.ErrSubscriptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case form.ErrTooManyTeams:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func regenerateID(w http.ResponseWriter, r *http.Request, formHandler form.Handler, subscriptionID string) {
+	result, err := formHandler.RegenerateID(r.Context(), subscriptionID)
+	switch err {
+	case nil:
+		respond(w, r, http.StatusOK, result)
+	case form.ErrSubscriptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func confirmation(w http.ResponseWriter, r *http.Request, formHandler form.Handler, subscriptionID string) {
+	// rendered completely first so a failure does not leave half a page
+	var buffer bytes.Buffer
+	switch err := formHandler.Confirmation(r.Context(), subscriptionID, &buffer); err {
+	case nil:
+		w.Header().Set("content-type", htmlContentType)
+		w.Write(buffer.Bytes())
+	case form.ErrSubscriptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.WithField("error", err).Error("Failed to render confirmation")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+func deleteSubscription(w http.ResponseWriter, r *http.Request, formHandler form.Handler, subscriptionID string, adminTokens []string) {
+	if !checkAdminToken(w, r, adminTokens) {
+		return
+	}
+
+	switch err := formHandler.Delete(r.Context(), subscriptionID); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case form.ErrSubscriptionNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
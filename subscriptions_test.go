@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// request sends a request with body to the subscriptions handler, headers are added as given
+func request(handler http.HandlerFunc, method string, path string, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+// handleValid handles validBody, failing the test when it is not stored
+func handleValid(t *testing.T, formHandler form.Handler) form.Result {
+	msg, err := form.DecodeMessage(strings.NewReader(validBody), false)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	result, err := formHandler.Handle(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+
+	return result
+}
+
+func TestAddTeam(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{MaxTeams: 2})
+	defer formHandler.Close()
+
+	result := handleValid(t, formHandler)
+
+	handler := subscriptionsHandler(formHandler, nil, 1<<20)
+	path := "/subscriptions/" + result.SubscriptionID + "/teams"
+
+	rec := request(handler, http.MethodPost, path, `{"name": "Dames 1", "type": "Women", "level": "National", "language": "EN"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var added form.Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &added); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+	if added.Teams != 2 {
+		t.Errorf("expected 2 teams, got %d", added.Teams)
+	}
+	if teams := store.Teams(result.SubscriptionID); len(teams) != 2 || teams[1] != "Dames 1" {
+		t.Errorf("expected Dames 1 to be added, got %v", teams)
+	}
+
+	// the subscription has the maximum number of teams now
+	rec = request(handler, http.MethodPost, path, `{"name": "Heren 2", "type": "Heren", "level": "Regio 1"}`)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 beyond the maximum number of teams, got %d", rec.Code)
+	}
+}
+
+func TestAddTeamToUnknownSubscription(t *testing.T) {
+	_, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	rec := request(subscriptionsHandler(formHandler, nil, 1<<20), http.MethodPost, "/subscriptions/999999/teams",
+		`{"name": "Dames 1", "type": "Dames", "level": "Regio 1"}`)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestAddInvalidTeam(t *testing.T) {
+	_, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	rec := request(subscriptionsHandler(formHandler, nil, 1<<20), http.MethodPost, "/subscriptions/999999/teams", `{"type": "Dames"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a team without name to be rejected with 400, got %d", rec.Code)
+	}
+}
+
+func TestAddTeamRejectsBodyOverLimit(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	result := handleValid(t, formHandler)
+
+	body := `{"name": "Dames 1", "type": "Dames", "level": "Regio 1"}`
+	rec := request(subscriptionsHandler(formHandler, nil, int64(len(body)-1)), http.MethodPost,
+		"/subscriptions/"+result.SubscriptionID+"/teams", body)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+	if teams := store.Teams(result.SubscriptionID); len(teams) != 1 {
+		t.Errorf("expected no team to be added, got %v", teams)
+	}
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	_, formHandler, store := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	result := handleValid(t, formHandler)
+
+	handler := subscriptionsHandler(formHandler, []string{"admin"}, 1<<20)
+	path := "/subscriptions/" + result.SubscriptionID
+
+	if rec := request(handler, http.MethodDelete, path, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without admin token, got %d", rec.Code)
+	}
+	if rec := request(handler, http.MethodDelete, path, "", "X-admin-token", "admin"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+	if store.Registrations() != 0 {
+		t.Errorf("expected the registration to be deleted, got %d registrations", store.Registrations())
+	}
+
+	if rec := request(handler, http.MethodDelete, path, "", "X-admin-token", "admin"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted subscription, got %d", rec.Code)
+	}
+}
+
+func TestConfirmation(t *testing.T) {
+	hook, formHandler, _ := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	body := strings.Replace(validBody, `"SBC2000"`, `"<script>alert(1)</script> & Co"`, 1)
+	rec := post(hook, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var result form.Result
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("expected a JSON response: %v", err)
+	}
+
+	handler := subscriptionsHandler(formHandler, nil, 1<<20)
+	rec = request(handler, http.MethodGet, "/subscriptions/"+result.SubscriptionID+"/confirmation", "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML page, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	page := rec.Body.String()
+	for _, expected := range []string{
+		"Bevestiging inschrijving " + result.SubscriptionID,
+		"Jan Jansen", "jan@example.com", "612345678", "Heren 1", "Regio 1", "01-05-2018",
+		"&lt;script&gt;alert(1)&lt;/script&gt; &amp; Co",
+	} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("expected the confirmation to contain %q", expected)
+		}
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("expected the club name to be escaped")
+	}
+
+	if rec = request(handler, http.MethodGet, "/subscriptions/999999/confirmation", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown subscription, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/SBC2000/registration-handler/form"
+)
+
+// tenant is a competition sharing this deployment, its registrations are stored in its own schema.
+// Messages are routed to a tenant by the X-tenant header or by the prefix of the form title.
+type tenant struct {
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	Schema string `json:"schema"`
+	// Secrets replace the webhook secrets for the messages of this tenant
+	Secrets []string `json:"secrets"`
+
+	handler form.Handler
+}
+
+// loadTenants reads the tenants from the JSON file named by TENANTS_FILE, without it there are none
+func loadTenants(config form.Config) (tenants []*tenant, err error) {
+	file := os.Getenv("TENANTS_FILE")
+	if file == "" {
+		return
+	}
+
+	var buffer []byte
+	if buffer, err = ioutil.ReadFile(file); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(buffer, &tenants); err != nil {
+		return
+	}
+
+	for _, t := range tenants {
+		db, err := openDB(t.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.handler, err = form.NewHandler(newStore(db, config.Location), config); err != nil {
+			return nil, err
+		}
+
+		log.WithFields(log.Fields(map[string]interface{}{
+			"tenant": t.Name,
+			"prefix": t.Prefix,
+			"schema": t.Schema,
+		})).Info("Loaded tenant")
+	}
+
+	return
+}
+
+// errUnknownTenant is returned for an X-tenant header that names none of the tenants
+var errUnknownTenant = errors.New("Unknown tenant")
+
+// resolveTenant finds the tenant of a message and returns the title without the tenant prefix,
+// the tenant is nil for messages of the default tenant. A name that matches no tenant is an error
+// rather than the default tenant, so a misconfigured form does not store in the wrong schema.
+func resolveTenant(tenants []*tenant, name string, title string) (*tenant, string, error) {
+	if name != "" {
+		for _, t := range tenants {
+			if t.Name == name {
+				return t, strings.TrimSpace(strings.TrimPrefix(title, t.Prefix)), nil
+			}
+		}
+		return nil, title, errUnknownTenant
+	}
+
+	for _, t := range tenants {
+		if t.Prefix != "" && strings.HasPrefix(title, t.Prefix) {
+			return t, strings.TrimSpace(strings.TrimPrefix(title, t.Prefix)), nil
+		}
+	}
+
+	return nil, title, nil
+}
+
+// withSearchPath adds the schema as search_path runtime parameter to a Postgres connection string,
+// either a URL or key=value pairs
+func withSearchPath(dataSource string, schema string) string {
+	if u, err := url.Parse(dataSource); err == nil && u.Scheme != "" {
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	return dataSource + " search_path=" + schema
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SBC2000/registration-handler/form"
+	"github.com/SBC2000/registration-handler/form/formtest"
+)
+
+// newTestTenant creates a tenant storing in its own memory store, the caller closes its handler
+func newTestTenant(t *testing.T, name string, prefix string, secrets ...string) (*tenant, *formtest.MemoryStore) {
+	store := formtest.NewMemoryStore()
+	clock := &formtest.Clock{T: time.Date(2018, time.May, 1, 12, 0, 0, 0, time.UTC)}
+	handler, err := form.NewHandlerWith(store, clock, &formtest.IDs{}, form.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+
+	return &tenant{Name: name, Prefix: prefix, Schema: name, Secrets: secrets, handler: handler}, store
+}
+
+func TestHookRoutesTenants(t *testing.T) {
+	_, formHandler, defaultStore := newTestHook(t, form.Config{})
+	defer formHandler.Close()
+
+	beach, beachStore := newTestTenant(t, "beach", "Beach:")
+	defer beach.handler.Close()
+	indoor, indoorStore := newTestTenant(t, "indoor", "Indoor:", "indoor-secret")
+	defer indoor.handler.Close()
+
+	settings := testSettings(formHandler)
+	settings.tenants = []*tenant{beach, indoor}
+	hook := hookHandler(settings)
+
+	withTitle := func(title string) string {
+		return strings.Replace(validBody, `"Inschrijven teams"`, `"`+title+`"`, 1)
+	}
+
+	if rec := post(hook, withTitle("Beach: Inschrijven teams")); rec.Code != http.StatusOK {
+		t.Fatalf("expected the beach tenant to accept the message, got %d", rec.Code)
+	}
+	if rec := post(hook, withTitle("Indoor: Inschrijven teams"), "X-hook-secret", "indoor-secret"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the indoor tenant to accept the message, got %d", rec.Code)
+	}
+	// the header selects a tenant regardless of the title
+	if rec := post(hook, validBody, "X-tenant", "beach"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the beach tenant to accept the message, got %d", rec.Code)
+	}
+	if rec := post(hook, validBody); rec.Code != http.StatusOK {
+		t.Fatalf("expected the default tenant to accept the message, got %d", rec.Code)
+	}
+
+	if beachStore.Registrations() != 1 || indoorStore.Registrations() != 1 || defaultStore.Registrations() != 1 {
+		t.Errorf("expected a registration per tenant, got beach %d, indoor %d and default %d",
+			beachStore.Registrations(), indoorStore.Registrations(), defaultStore.Registrations())
+	}
+
+	// a misspelled tenant must not end up in the default schema
+	if rec := post(hook, validBody, "X-tenant", "beech"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown tenant to be refused, got %d", rec.Code)
+	}
+	if defaultStore.Registrations() != 1 {
+		t.Errorf("expected nothing stored for an unknown tenant, got %d default registrations", defaultStore.Registrations())
+	}
+
+	// the secrets of a tenant replace the default secrets
+	if rec := post(hook, withTitle("Indoor: Inschrijven teams")); rec.Code != http.StatusForbidden {
+		t.Errorf("expected the default secret to be refused by the indoor tenant, got %d", rec.Code)
+	}
+}
+
+func TestWithSearchPath(t *testing.T) {
+	tests := []struct {
+		dataSource string
+		expected   string
+	}{
+		{"postgres://user@localhost/registrations", "postgres://user@localhost/registrations?search_path=beach"},
+		{"postgres://localhost/registrations?sslmode=disable", "postgres://localhost/registrations?search_path=beach&sslmode=disable"},
+		{"host=localhost dbname=registrations", "host=localhost dbname=registrations search_path=beach"},
+	}
+
+	for _, test := range tests {
+		if dataSource := withSearchPath(test.dataSource, "beach"); dataSource != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, dataSource)
+		}
+	}
+}